@@ -0,0 +1,88 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package eventlog records events to a compact binary log, and reads them
+// back so that they can be replayed through any event.Handler.
+//
+// A log starts with an 8 byte header, "goevlog" followed by a version byte.
+// It is followed by one record per event, each of which is a uvarint length
+// followed by that many bytes of encoded event:
+//
+//	uvarint ID
+//	uvarint Parent
+//	varint  Kind
+//	varint  At, in nanoseconds since the Unix epoch, or 0 for no time
+//	string  Source.Space, Source.Owner, Source.Name
+//	uvarint number of labels, followed by the labels
+//
+// where a string is a uvarint length followed by its bytes, and a label is
+// its name as a string, a tag byte that identifies the type of its value, and
+// the value.
+//
+// The typed label values (strings, bytes, integers, floats, bools, durations
+// and groups) are read back exactly. Of the values stored with event.Value,
+// metrics, severity levels, errors and error chains are preserved well enough
+// for handlers to treat them as the originals; nil stays nil, and any other
+// value is recorded as its fmt.Sprint text and read back as a string.
+//
+// Dynamic kinds created with event.NewKind are recorded by number, so they are
+// only meaningful when read by the same program.
+package eventlog
+
+import "errors"
+
+// magic is the header at the start of each log.
+// Version 2 added the aggregation and buckets of metrics, logs of version 1
+// can still be read.
+const (
+	magic   = "goevlog\x02"
+	version = 2
+)
+
+// maxRecord bounds the size of a record, so that a corrupt length does not
+// cause a huge allocation.
+const maxRecord = 64 << 20
+
+// Tags identifying the type of a label value.
+const (
+	tagNone     = byte(iota) // a label with no value
+	tagString                // string
+	tagBytes                 // string
+	tagInt64                 // varint
+	tagUint64                // uvarint
+	tagFloat64               // 8 bytes, IEEE 754 little endian
+	tagBool                  // byte
+	tagDuration              // varint
+	tagGroup                 // uvarint count, then labels
+	tagNil                   // a nil event.Value
+	tagText                  // string, from fmt.Sprint of the value
+	tagLevel                 // uvarint severity.Level
+	tagError                 // string type, string message
+	tagCauses                // uvarint count, then type and message strings
+	tagMetric                // byte metric type, then name, namespace, description and unit strings
+)
+
+// Metric types, for tagMetric.
+const (
+	metricOther = byte(iota)
+	metricCounter
+	metricFloatGauge
+	metricDuration
+	metricIntDistribution
+)
+
+// maxGroupDepth is how deeply groups of labels may be nested in a record.
+// Groups nested any deeper are written as text.
+const maxGroupDepth = 64
+
+var errCorrupt = errors.New("eventlog: corrupt record")
+
+// Error is an error read back from a log.
+// It has the message and the dynamic type of the original error.
+type Error struct {
+	Type string // the original type, as printed by %T
+	Msg  string
+}
+
+func (e *Error) Error() string { return e.Msg }
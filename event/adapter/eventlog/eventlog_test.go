@@ -0,0 +1,375 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package eventlog_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/adapter/eventlog"
+	"golang.org/x/exp/event/eventtest"
+	"golang.org/x/exp/event/severity"
+)
+
+var counter = event.NewCounter("hits", &event.MetricOptions{Description: "Number of hits", Unit: event.UnitDimensionless})
+
+// compareMetrics compares metrics by name and options, as metrics read from a
+// log are not the ones that were written.
+var compareMetrics = cmp.Comparer(func(a, b event.Metric) bool {
+	ao, bo := a.Options(), b.Options()
+	return a.Name() == b.Name() &&
+		cmp.Equal(ao.Buckets.Bounds(), bo.Buckets.Bounds()) &&
+		(ao.Buckets == nil) == (bo.Buckets == nil) &&
+		ao.Namespace == bo.Namespace &&
+		ao.Description == bo.Description &&
+		ao.Unit == bo.Unit &&
+		ao.Aggregation == bo.Aggregation
+})
+
+var histogram = event.NewIntDistribution("sizes", &event.MetricOptions{
+	Unit:        event.UnitBytes,
+	Aggregation: event.AggregateLastValue,
+	Buckets:     event.ExplicitBuckets(10, 100, 1000),
+})
+
+func TestRoundTrip(t *testing.T) {
+	at := time.Date(2020, 3, 5, 14, 27, 48, 123456789, time.UTC)
+	wrapped := fmt.Errorf("wrapped: %w", io.EOF)
+	for _, test := range []struct {
+		name  string
+		label event.Label
+		want  interface{} // the Interface() of the label read back
+	}{
+		{"none", event.Label{Name: "none"}, nil},
+		{"string", event.String("s", "a \"string\""), "a \"string\""},
+		{"bytes", event.Bytes("b", []byte{0, 1, 2}), []byte{0, 1, 2}},
+		{"int64", event.Int64("i", -67), int64(-67)},
+		{"uint64", event.Uint64("u", math.MaxUint64), uint64(math.MaxUint64)},
+		{"float64", event.Float64("f", 263.2), 263.2},
+		{"nan", event.Float64("f", math.Inf(-1)), math.Inf(-1)},
+		{"bool", event.Bool("t", true), true},
+		{"duration", event.Duration("d", 1500*time.Millisecond), 1500 * time.Millisecond},
+		{"nil", event.Value("nil", nil), nil},
+		{"level", severity.Warning.Label(), severity.Warning},
+		{"error", event.Value("error", wrapped), &eventlog.Error{Type: "*fmt.wrapError", Msg: "wrapped: EOF"}},
+		{"causes", event.ErrorCauses.Of(event.ErrorChain(wrapped)), event.Causes{
+			&eventlog.Error{Type: "*fmt.wrapError", Msg: "wrapped: EOF"},
+			&eventlog.Error{Type: "*errors.errorString", Msg: "EOF"},
+		}},
+		{"metric", event.MetricKey.Of(counter), counter},
+		{"histogram", event.MetricKey.Of(histogram), histogram},
+		{"other", event.Value("other", struct{ A int }{3}), "{3}"},
+		{"group", event.Group("g", event.Int64("a", 1), event.Group("h", event.String("b", "c"))),
+			[]event.Label{event.Int64("a", 1), event.Group("h", event.String("b", "c"))}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			in := &event.Event{
+				ID:     3,
+				Parent: 2,
+				Kind:   event.LogKind,
+				At:     at,
+				Source: event.Source{Space: "golang.org/x/exp/event", Owner: "Printer", Name: "Event"},
+				Labels: []event.Label{test.label, event.String("msg", "message")},
+			}
+			var buf bytes.Buffer
+			h := eventlog.NewHandler(&buf)
+			h.Event(context.Background(), in)
+			if err := h.Err(); err != nil {
+				t.Fatal(err)
+			}
+			r := eventlog.NewReader(&buf)
+			got, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.At.Equal(at) {
+				t.Errorf("got time %v, want %v", got.At, at)
+			}
+			want := *in
+			want.Labels = nil
+			gotMeta := *got
+			gotMeta.Labels = nil
+			if diff := cmp.Diff(want, gotMeta, eventtest.CmpOptions()...); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+			if len(got.Labels) != 2 {
+				t.Fatalf("got %d labels, want 2", len(got.Labels))
+			}
+			l := got.Labels[0]
+			if l.Name != test.label.Name {
+				t.Errorf("got name %q, want %q", l.Name, test.label.Name)
+			}
+			if diff := cmp.Diff(test.want, labelValue(l), compareMetrics); diff != "" {
+				t.Errorf("value mismatch (-want, +got):\n%s", diff)
+			}
+			if _, err := r.Next(); err != io.EOF {
+				t.Errorf("got %v at end of log, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func labelValue(l event.Label) interface{} {
+	switch {
+	case !l.HasValue():
+		return nil
+	case l.IsBytes():
+		return l.Bytes()
+	default:
+		return l.Interface()
+	}
+}
+
+// tee is a handler that delivers events to two handlers.
+type tee struct{ a, b event.Handler }
+
+func (t tee) Event(ctx context.Context, ev *event.Event) context.Context {
+	t.a.Event(ctx, ev)
+	return t.b.Event(ctx, ev)
+}
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	recorded := &eventtest.CaptureHandler{}
+	opts := eventtest.ExporterOptions()
+	opts.EnableNamespaces = true
+	ctx := event.WithExporter(context.Background(),
+		event.NewExporter(tee{eventlog.NewHandler(&buf), recorded}, opts))
+	ctx = event.Start(ctx, "span", event.Int64("a", 1))
+	severity.Info.Log(ctx, "info")
+	event.Error(ctx, "failed", errors.New("an error"))
+	counter.Record(ctx, 3)
+	counter.Record(ctx, 4)
+	event.End(ctx)
+
+	replayed := &eventtest.CaptureHandler{}
+	// a different clock and no namespaces, so that only recorded values are
+	// delivered
+	rctx := event.WithExporter(context.Background(), event.NewExporter(replayed, nil))
+	if err := eventlog.NewReader(&buf).Replay(rctx); err != nil {
+		t.Fatal(err)
+	}
+	// compare label values, rather than the identity of metrics and errors
+	type value struct {
+		Name  string
+		Value interface{}
+	}
+	labelValues := cmp.Transformer("Label", func(l event.Label) value {
+		v := value{Name: l.Name, Value: labelValue(l)}
+		switch x := v.Value.(type) {
+		case event.Metric:
+			v.Value = fmt.Sprintf("%s %+v", x.Name(), x.Options())
+		case error:
+			v.Value = x.Error()
+		}
+		return v
+	})
+	if diff := cmp.Diff(recorded.Got, replayed.Got, append(eventtest.CmpOptions(), labelValues)...); diff != "" {
+		t.Errorf("mismatch (-recorded, +replayed):\n%s", diff)
+	}
+	for i, ev := range replayed.Got {
+		if !ev.At.Equal(recorded.Got[i].At) {
+			t.Errorf("event %d: got time %v, want %v", i, ev.At, recorded.Got[i].At)
+		}
+	}
+	// both metric events must refer to the same metric
+	var metrics []event.Metric
+	for _, ev := range replayed.Got {
+		if m, ok := event.MetricKey.Find(&ev); ok {
+			metrics = append(metrics, m.(event.Metric))
+		}
+	}
+	if len(metrics) != 2 || metrics[0] != metrics[1] {
+		t.Errorf("got metrics %v, want the same metric twice", metrics)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	h := eventlog.NewHandler(&buf)
+	ctx := event.WithExporter(context.Background(), event.NewExporter(h, eventtest.ExporterOptions()))
+	event.Log(ctx, "first")
+	complete := buf.Len()
+	event.Log(ctx, "second")
+
+	for _, test := range []struct {
+		name string
+		size int
+		want []error
+	}{
+		{"empty", 0, []error{io.EOF}},
+		{"header", 8, []error{io.EOF}},
+		{"complete", complete, []error{nil, io.EOF}},
+		{"length", complete + 1, []error{nil, io.ErrUnexpectedEOF}},
+		{"record", buf.Len() - 1, []error{nil, io.ErrUnexpectedEOF}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := eventlog.NewReader(bytes.NewReader(buf.Bytes()[:test.size]))
+			for i, want := range test.want {
+				if _, err := r.Next(); err != want {
+					t.Errorf("Next %d: got error %v, want %v", i, err, want)
+				}
+			}
+		})
+	}
+
+	if _, err := eventlog.NewReader(bytes.NewReader([]byte("time=2020/03/05 msg=\"a\"\n"))).Next(); err == nil {
+		t.Error("reading a text log succeeded, want an error")
+	}
+}
+
+func TestMetricBuckets(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := event.WithExporter(context.Background(), event.NewExporter(eventlog.NewHandler(&buf), eventtest.ExporterOptions()))
+	for _, buckets := range []*event.Buckets{nil, event.ExplicitBuckets(), event.ExplicitBuckets(1, 2)} {
+		event.NewIntDistribution("sizes", &event.MetricOptions{Buckets: buckets}).Record(ctx, 1)
+	}
+	r := eventlog.NewReader(&buf)
+	var metrics []event.Metric
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, _ := event.MetricKey.Find(ev)
+		metrics = append(metrics, m.(event.Metric))
+	}
+	if len(metrics) != 3 || metrics[0] == metrics[1] || metrics[1] == metrics[2] {
+		t.Fatalf("got metrics %v, want three different metrics", metrics)
+	}
+	if b := metrics[0].Options().Buckets; b != nil {
+		t.Errorf("got buckets %v, want none", b.Bounds())
+	}
+	if b := metrics[1].Options().Buckets; b == nil || len(b.Bounds()) != 0 {
+		t.Errorf("got buckets %v, want buckets with no bounds", b)
+	}
+	if got := metrics[2].Options().Buckets.Bounds(); !cmp.Equal(got, []float64{1, 2}) {
+		t.Errorf("got bounds %v, want [1 2]", got)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := event.WithExporter(context.Background(), event.NewExporter(eventlog.NewHandler(&buf), eventtest.ExporterOptions()))
+	event.Log(ctx, "message")
+	log := buf.Bytes()
+	// logs of version 1 only differ in the encoding of metrics
+	log[7] = 1
+	if _, err := eventlog.NewReader(bytes.NewReader(log)).Next(); err != nil {
+		t.Errorf("reading a version 1 log: %v", err)
+	}
+	log[7] = 3
+	if _, err := eventlog.NewReader(bytes.NewReader(log)).Next(); err == nil {
+		t.Error("reading a version 3 log succeeded, want an error")
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	h, err := eventlog.NewFileHandler(path, &eventlog.FileOptions{MaxSize: 100, MaxFiles: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := event.WithExporter(context.Background(), event.NewExporter(h, eventtest.ExporterOptions()))
+	const n = 50
+	for i := 0; i < n; i++ {
+		event.Log(ctx, "message", event.Int64("i", int64(i)))
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := eventlog.Files(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{path + ".2", path + ".1", path}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Fatalf("files mismatch (-want, +got):\n%s", diff)
+	}
+	// the files hold the most recent events, in order
+	var ids []uint64
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := eventlog.NewReader(f)
+		for {
+			ev, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			ids = append(ids, ev.ID)
+		}
+		f.Close()
+	}
+	if len(ids) == 0 || len(ids) == n {
+		t.Fatalf("got %d events, want some but not all of the %d events", len(ids), n)
+	}
+	for i, id := range ids {
+		if want := uint64(n - len(ids) + i + 1); id != want {
+			t.Fatalf("event %d has ID %d, want %d", i, id, want)
+		}
+	}
+}
+
+func TestNestedGroups(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := event.WithExporter(context.Background(), event.NewExporter(eventlog.NewHandler(&buf), eventtest.ExporterOptions()))
+	l := event.String("leaf", "a")
+	for i := 0; i < 100; i++ {
+		l = event.Group("g", l)
+	}
+	event.Log(ctx, "deep", l)
+	r := eventlog.NewReader(bytes.NewReader(buf.Bytes()))
+	ev, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth := 0
+	for l = ev.Find("g"); l.IsGroup(); l = l.Group()[0] {
+		depth++
+	}
+	if depth != 64 || !l.IsString() {
+		t.Errorf("read %d nested groups then %v, want 64 then the rest as text", depth, l)
+	}
+
+	// a record with more nested groups than the writer writes
+	const tagGroup = 8
+	record := []byte{0, 0, 0, 0, 0, 0, 0} // ID, parent, kind, time, source
+	for i := 0; i < 1000; i++ {
+		record = append(record, 1, 0, tagGroup) // one label, no name, a group
+	}
+	record = append(record, 0)
+	log := append([]byte{}, buf.Bytes()[:8]...)
+	log = append(log, byte(len(record)&0x7f|0x80), byte(len(record)>>7))
+	log = append(log, record...)
+	if _, err := eventlog.NewReader(bytes.NewReader(log)).Next(); err == nil || !strings.Contains(err.Error(), "nested") {
+		t.Errorf("reading 1000 nested groups: got error %v, want one about nesting", err)
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eventlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/severity"
+)
+
+// Reader reads the events of a log.
+type Reader struct {
+	r       *bufio.Reader
+	buf     []byte
+	version byte // of the log, once its header is read
+	metrics map[metricID]event.Metric
+}
+
+// metricID identifies a metric read from a log, so that all the events of a
+// metric refer to the same event.Metric, as handlers that aggregate metrics
+// expect.
+type metricID struct {
+	typ                                byte
+	name, namespace, description, unit string
+	aggregation                        event.Aggregation
+	buckets                            string // as encoded
+}
+
+// NewReader returns a reader for the log in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), metrics: map[metricID]event.Metric{}}
+}
+
+// Next reads the next event of the log.
+// It returns io.EOF at the end of the log, and io.ErrUnexpectedEOF if the log
+// ends part way through an event, as it does if the process writing it
+// stopped abruptly.
+// The ID, Parent, At and Source of the event are as they were recorded.
+func (r *Reader) Next() (*event.Event, error) {
+	if r.version == 0 {
+		var hdr [len(magic)]byte
+		if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errors.New("eventlog: missing header")
+			}
+			return nil, err
+		}
+		v := hdr[len(hdr)-1]
+		if string(hdr[:len(hdr)-1]) != magic[:len(magic)-1] || v < 1 || v > version {
+			return nil, errors.New("eventlog: not an event log, or an unknown version")
+		}
+		r.version = v
+	}
+	size, err := binary.ReadUvarint(r.r)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		// binary.ReadUvarint only returns io.EOF if no bytes were read
+		return nil, err
+	case err != nil:
+		return nil, errCorrupt
+	}
+	if size > maxRecord {
+		return nil, errCorrupt
+	}
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	d := decoder{buf: r.buf, r: r}
+	ev := d.event()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.buf) != 0 {
+		return nil, errCorrupt
+	}
+	return ev, nil
+}
+
+// Replay delivers all the remaining events of the log to the exporter found
+// in ctx, and returns the first error reading the log, or nil at its end.
+// The events keep their recorded IDs, times and sources, but are subject to
+// the exporter's Policy and Sampler.
+// The exporter does not know about the recorded IDs, so events created by the
+// program while replaying may reuse them.
+func (r *Reader) Replay(ctx context.Context) error {
+	for {
+		recorded, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ev := event.New(ctx, recorded.Kind)
+		if ev == nil {
+			continue
+		}
+		ev.ID = recorded.ID
+		ev.Parent = recorded.Parent
+		ev.At = recorded.At
+		ev.Source = recorded.Source
+		ev.Labels = append(ev.Labels, recorded.Labels...)
+		ev.Deliver()
+	}
+}
+
+// decoder decodes a single record.
+// Any error is recorded in err, after which all methods return zero values.
+type decoder struct {
+	buf []byte
+	r   *Reader
+	err error
+}
+
+func (d *decoder) event() *event.Event {
+	ev := &event.Event{}
+	ev.ID = d.uvarint()
+	ev.Parent = d.uvarint()
+	ev.Kind = event.Kind(d.varint())
+	if at := d.varint(); at != 0 {
+		ev.At = time.Unix(0, at)
+	}
+	ev.Source.Space = d.string()
+	ev.Source.Owner = d.string()
+	ev.Source.Name = d.string()
+	ev.Labels = d.labels(0)
+	return ev
+}
+
+// labels decodes labels that are depth groups deep.
+func (d *decoder) labels(depth int) []event.Label {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) { // every label takes at least one byte
+		d.fail()
+		return nil
+	}
+	labels := make([]event.Label, 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		labels = append(labels, d.label(depth))
+	}
+	return labels
+}
+
+func (d *decoder) label(depth int) event.Label {
+	name := d.string()
+	switch tag := d.byte(); tag {
+	case tagNone:
+		return event.Label{Name: name}
+	case tagString:
+		return event.String(name, d.string())
+	case tagBytes:
+		return event.Bytes(name, []byte(d.string()))
+	case tagInt64:
+		return event.Int64(name, d.varint())
+	case tagUint64:
+		return event.Uint64(name, d.uvarint())
+	case tagFloat64:
+		return event.Float64(name, d.float64())
+	case tagBool:
+		return event.Bool(name, d.byte() != 0)
+	case tagDuration:
+		return event.Duration(name, time.Duration(d.varint()))
+	case tagGroup:
+		if depth >= maxGroupDepth {
+			d.err = fmt.Errorf("eventlog: label groups nested more than %d deep", maxGroupDepth)
+			return event.Label{}
+		}
+		return event.Group(name, d.labels(depth+1)...)
+	case tagNil:
+		return event.Value(name, nil)
+	case tagText:
+		return event.String(name, d.string())
+	case tagLevel:
+		return event.Value(name, severity.Level(d.uvarint()))
+	case tagError:
+		return event.Value(name, d.error())
+	case tagCauses:
+		n := d.uvarint()
+		if n > uint64(len(d.buf)) {
+			d.fail()
+			return event.Label{}
+		}
+		causes := make(event.Causes, 0, n)
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			causes = append(causes, d.error())
+		}
+		return event.Value(name, causes)
+	case tagMetric:
+		return event.Value(name, d.metric())
+	default:
+		d.err = fmt.Errorf("eventlog: unknown label tag %d", tag)
+		return event.Label{}
+	}
+}
+
+func (d *decoder) error() error {
+	typ := d.string()
+	return &Error{Type: typ, Msg: d.string()}
+}
+
+// metric returns the metric described by the record, creating it the first
+// time it is seen.
+func (d *decoder) metric() event.Metric {
+	id := metricID{typ: d.byte()}
+	id.name = d.string()
+	id.namespace = d.string()
+	id.description = d.string()
+	id.unit = d.string()
+	var bounds []float64
+	if d.r.version >= 2 {
+		id.aggregation = event.Aggregation(d.uvarint())
+		start := d.buf
+		bounds = d.bounds()
+		id.buckets = string(start[:len(start)-len(d.buf)])
+	}
+	if d.err != nil {
+		return nil
+	}
+	if m, ok := d.r.metrics[id]; ok {
+		return m
+	}
+	opts := &event.MetricOptions{
+		Namespace:   id.namespace,
+		Description: id.description,
+		Unit:        event.Unit(id.unit),
+		Aggregation: id.aggregation,
+	}
+	if bounds != nil {
+		opts.Buckets = event.ExplicitBuckets(bounds...)
+	}
+	var m event.Metric
+	switch id.typ {
+	case metricCounter:
+		m = event.NewCounter(id.name, opts)
+	case metricFloatGauge:
+		m = event.NewFloatGauge(id.name, opts)
+	case metricDuration:
+		m = event.NewDuration(id.name, opts)
+	case metricIntDistribution:
+		m = event.NewIntDistribution(id.name, opts)
+	default:
+		m = &metric{name: id.name, opts: *opts}
+	}
+	d.r.metrics[id] = m
+	return m
+}
+
+// bounds returns the bounds of the buckets of a metric, which is nil if it
+// has no buckets.
+func (d *decoder) bounds() []float64 {
+	n := d.uvarint()
+	if d.err != nil || n == 0 {
+		return nil
+	}
+	n--
+	if n > uint64(len(d.buf))/8 {
+		d.fail()
+		return nil
+	}
+	bounds := make([]float64, n)
+	for i := range bounds {
+		bounds[i] = d.float64()
+		// event.ExplicitBuckets panics on bounds that are not increasing
+		if math.IsNaN(bounds[i]) || (i > 0 && bounds[i] <= bounds[i-1]) {
+			d.fail()
+			return nil
+		}
+	}
+	return bounds
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errCorrupt
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.buf) == 0 {
+		d.fail()
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) float64() float64 {
+	if d.err != nil || len(d.buf) < 8 {
+		d.fail()
+		return 0
+	}
+	f := math.Float64frombits(binary.LittleEndian.Uint64(d.buf))
+	d.buf = d.buf[8:]
+	return f
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil || n > uint64(len(d.buf)) {
+		d.fail()
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
+
+// metric is a metric of a type this package does not know about.
+type metric struct {
+	name string
+	opts event.MetricOptions
+}
+
+func (m *metric) Name() string                 { return m.name }
+func (m *metric) Options() event.MetricOptions { return m.opts }
@@ -0,0 +1,385 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eventlog
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/severity"
+)
+
+// Encoder writes events to a log.
+type Encoder struct {
+	w          io.Writer
+	buf        []byte
+	hdr        [binary.MaxVarintLen64]byte
+	wroteMagic bool
+}
+
+// NewEncoder returns an encoder that writes a log to w.
+// The log header is written with the first event.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes a single event to the log.
+func (e *Encoder) Encode(ev *event.Event) error {
+	if !e.wroteMagic {
+		if _, err := io.WriteString(e.w, magic); err != nil {
+			return err
+		}
+		e.wroteMagic = true
+	}
+	e.buf = appendEvent(e.buf[:0], ev)
+	n := binary.PutUvarint(e.hdr[:], uint64(len(e.buf)))
+	if _, err := e.w.Write(e.hdr[:n]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// Handler is an event.Handler that writes every event to a log.
+type Handler struct {
+	enc *Encoder
+	err error
+}
+
+// NewHandler returns a handler that writes the events to the supplied writer.
+func NewHandler(to io.Writer) *Handler {
+	return &Handler{enc: NewEncoder(to)}
+}
+
+func (h *Handler) Event(ctx context.Context, ev *event.Event) context.Context {
+	if err := h.enc.Encode(ev); err != nil && h.err == nil {
+		h.err = err
+	}
+	return ctx
+}
+
+// Err returns the first error that occurred while writing events.
+func (h *Handler) Err() error { return h.err }
+
+// FileOptions controls the rotation of the files written by a FileHandler.
+type FileOptions struct {
+	// MaxSize is the size in bytes after which a file is rotated.
+	// If zero, the file is never rotated.
+	MaxSize int64
+	// MaxFiles is the number of rotated files to keep, in addition to the
+	// current one. If zero, all rotated files are kept.
+	MaxFiles int
+}
+
+// FileHandler is an event.Handler that writes every event to a log file,
+// rotating it as configured by its FileOptions.
+//
+// The current file is at the path given to NewFileHandler. When it is
+// rotated, it is renamed to path.1, any path.1 becomes path.2 and so on.
+// Every file is a complete log that can be read on its own; Files lists them
+// in the order they were written.
+type FileHandler struct {
+	path string
+	opts FileOptions
+
+	mu   sync.Mutex
+	f    *os.File
+	enc  *Encoder
+	size int64
+	err  error
+}
+
+// NewFileHandler creates or truncates the file at path, and returns a handler
+// that writes events to it.
+func NewFileHandler(path string, opts *FileOptions) (*FileHandler, error) {
+	h := &FileHandler{path: path}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if err := h.open(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *FileHandler) open() error {
+	f, err := os.Create(h.path)
+	if err != nil {
+		return err
+	}
+	h.f = f
+	h.enc = NewEncoder(&countWriter{w: f, n: &h.size})
+	h.size = 0
+	return nil
+}
+
+func (h *FileHandler) Event(ctx context.Context, ev *event.Event) context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.f == nil {
+		return ctx
+	}
+	if h.opts.MaxSize > 0 && h.size >= h.opts.MaxSize {
+		h.setErr(h.rotate())
+		if h.f == nil {
+			return ctx
+		}
+	}
+	h.setErr(h.enc.Encode(ev))
+	return ctx
+}
+
+// rotate closes the current file, renames the existing files and opens a new
+// current file.
+func (h *FileHandler) rotate() error {
+	err := h.f.Close()
+	h.f = nil
+	if err != nil {
+		return err
+	}
+	names, err := Files(h.path)
+	if err != nil {
+		return err
+	}
+	// names are oldest first, and end with the current file
+	for i := 0; i < len(names); i++ {
+		n := len(names) - i // the number the file will have after renaming
+		if h.opts.MaxFiles > 0 && n > h.opts.MaxFiles {
+			if err := os.Remove(names[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Rename(names[i], h.path+"."+strconv.Itoa(n)); err != nil {
+			return err
+		}
+	}
+	return h.open()
+}
+
+func (h *FileHandler) setErr(err error) {
+	if err != nil && h.err == nil {
+		h.err = err
+	}
+}
+
+// Err returns the first error that occurred while writing or rotating files.
+func (h *FileHandler) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Close closes the current file. Events delivered after Close are discarded.
+func (h *FileHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.f == nil {
+		return h.err
+	}
+	err := h.f.Close()
+	h.f = nil
+	h.setErr(err)
+	return err
+}
+
+// Files returns the log files written by a FileHandler for path, oldest
+// first, so that they can be read in the order the events were written.
+func Files(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(path) + "."
+	type numbered struct {
+		name string
+		n    int
+	}
+	var rotated []numbered
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		n, err := strconv.Atoi(e.Name()[len(prefix):])
+		if err != nil || n < 1 {
+			continue
+		}
+		rotated = append(rotated, numbered{path + "." + strconv.Itoa(n), n})
+	}
+	sort.Slice(rotated, func(i, j int) bool { return rotated[i].n > rotated[j].n })
+	var names []string
+	for _, r := range rotated {
+		names = append(names, r.name)
+	}
+	if _, err := os.Stat(path); err == nil {
+		names = append(names, path)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return names, nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+func appendEvent(buf []byte, ev *event.Event) []byte {
+	buf = appendUvarint(buf, ev.ID)
+	buf = appendUvarint(buf, ev.Parent)
+	buf = appendVarint(buf, int64(ev.Kind))
+	var at int64
+	if !ev.At.IsZero() {
+		at = ev.At.UnixNano()
+	}
+	buf = appendVarint(buf, at)
+	buf = appendString(buf, ev.Source.Space)
+	buf = appendString(buf, ev.Source.Owner)
+	buf = appendString(buf, ev.Source.Name)
+	return appendLabels(buf, ev.Labels, 0)
+}
+
+// appendLabels appends labels that are depth groups deep.
+func appendLabels(buf []byte, labels []event.Label, depth int) []byte {
+	buf = appendUvarint(buf, uint64(len(labels)))
+	for _, l := range labels {
+		buf = appendLabel(buf, l, depth)
+	}
+	return buf
+}
+
+func appendLabel(buf []byte, l event.Label, depth int) []byte {
+	buf = appendString(buf, l.Name)
+	switch {
+	case !l.HasValue():
+		return append(buf, tagNone)
+	case l.IsString():
+		return appendString(append(buf, tagString), l.String())
+	case l.IsBytes():
+		return appendBytes(append(buf, tagBytes), l.Bytes())
+	case l.IsInt64():
+		return appendVarint(append(buf, tagInt64), l.Int64())
+	case l.IsUint64():
+		return appendUvarint(append(buf, tagUint64), l.Uint64())
+	case l.IsFloat64():
+		return appendUint64(append(buf, tagFloat64), math.Float64bits(l.Float64()))
+	case l.IsBool():
+		b := byte(0)
+		if l.Bool() {
+			b = 1
+		}
+		return append(buf, tagBool, b)
+	case l.IsDuration():
+		return appendVarint(append(buf, tagDuration), int64(l.Duration()))
+	case l.IsGroup() && depth < maxGroupDepth:
+		return appendLabels(append(buf, tagGroup), l.Group(), depth+1)
+	}
+	switch v := l.Interface().(type) {
+	case nil:
+		return append(buf, tagNil)
+	case severity.Level:
+		return appendUvarint(append(buf, tagLevel), uint64(v))
+	case event.Metric:
+		return appendMetric(append(buf, tagMetric), v)
+	case event.Causes:
+		buf = appendUvarint(append(buf, tagCauses), uint64(len(v)))
+		for _, err := range v {
+			buf = appendError(buf, err)
+		}
+		return buf
+	case error:
+		return appendError(append(buf, tagError), v)
+	default:
+		return appendString(append(buf, tagText), fmt.Sprint(v))
+	}
+}
+
+func appendError(buf []byte, err error) []byte {
+	typ := fmt.Sprintf("%T", err)
+	if e, ok := err.(*Error); ok {
+		// keep the type of an error that was itself read from a log
+		typ = e.Type
+	}
+	buf = appendString(buf, typ)
+	return appendString(buf, err.Error())
+}
+
+func appendMetric(buf []byte, m event.Metric) []byte {
+	var typ byte
+	switch m.(type) {
+	case *event.Counter:
+		typ = metricCounter
+	case *event.FloatGauge:
+		typ = metricFloatGauge
+	case *event.DurationDistribution:
+		typ = metricDuration
+	case *event.IntDistribution:
+		typ = metricIntDistribution
+	default:
+		typ = metricOther
+	}
+	opts := m.Options()
+	buf = append(buf, typ)
+	buf = appendString(buf, m.Name())
+	buf = appendString(buf, opts.Namespace)
+	buf = appendString(buf, opts.Description)
+	buf = appendString(buf, string(opts.Unit))
+	buf = appendUvarint(buf, uint64(opts.Aggregation))
+	// the number of bounds is offset by one, so that no buckets and buckets
+	// with no bounds are different
+	if opts.Buckets == nil {
+		return appendUvarint(buf, 0)
+	}
+	bounds := opts.Buckets.Bounds()
+	buf = appendUvarint(buf, uint64(len(bounds))+1)
+	for _, b := range bounds {
+		buf = appendUint64(buf, math.Float64bits(b))
+	}
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = appendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	return append(buf, tmp[:]...)
+}
@@ -44,10 +44,19 @@ func (l *logger) Log(keyvals ...interface{}) error {
 		if key == "msg" || key == "message" {
 			msg = fmt.Sprint(value)
 		} else {
-			ev.Labels = append(ev.Labels, event.Value(key, value))
+			ev.Labels = append(ev.Labels, newLabel(key, value))
 		}
 	}
 	ev.Labels = append(ev.Labels, event.String("msg", msg))
 	ev.Deliver()
 	return nil
 }
+
+// newLabel returns a label for a key/value pair.
+// Values of type map[string]interface{} become groups.
+func newLabel(key string, value interface{}) event.Label {
+	if m, ok := value.(map[string]interface{}); ok {
+		return event.Map(key, m)
+	}
+	return event.Value(key, value)
+}
@@ -34,3 +34,20 @@ func Test(t *testing.T) {
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
 	}
 }
+
+func TestGroups(t *testing.T) {
+	log := gokit.NewLogger()
+	ctx, h := eventtest.NewCapture()
+	log.Log(ctx, "msg", "mess", "http", map[string]interface{}{"method": "GET", "status": 200})
+	want := []event.Event{{
+		ID:   1,
+		Kind: event.LogKind,
+		Labels: []event.Label{
+			event.Group("http", event.Value("method", "GET"), event.Value("status", 200)),
+			event.String("msg", "mess"),
+		},
+	}}
+	if diff := cmp.Diff(want, h.Got, eventtest.CmpOptions()...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package json_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/adapter/json"
+	"golang.org/x/exp/event/eventtest"
+	"golang.org/x/exp/event/severity"
+)
+
+var (
+	jsonLog = eventtest.Hooks{
+		AStart: func(ctx context.Context, a int) context.Context {
+			severity.Info.Log(ctx, eventtest.A.Msg, event.Int64(eventtest.A.Name, int64(a)))
+			return ctx
+		},
+		AEnd: func(ctx context.Context) {},
+		BStart: func(ctx context.Context, b string) context.Context {
+			severity.Info.Log(ctx, eventtest.B.Msg, event.String(eventtest.B.Name, b))
+			return ctx
+		},
+		BEnd: func(ctx context.Context) {},
+	}
+
+	jsonLogf = eventtest.Hooks{
+		AStart: func(ctx context.Context, a int) context.Context {
+			severity.Info.Logf(ctx, eventtest.A.Msgf, a)
+			return ctx
+		},
+		AEnd: func(ctx context.Context) {},
+		BStart: func(ctx context.Context, b string) context.Context {
+			severity.Info.Logf(ctx, eventtest.B.Msgf, b)
+			return ctx
+		},
+		BEnd: func(ctx context.Context) {},
+	}
+)
+
+func jsonPrint(w io.Writer) context.Context {
+	h := json.NewHandler(w)
+	h.TimeFormat = eventtest.TimeFormat
+	h.Keys = json.Keys{ID: "-", Kind: "-"}
+	return event.WithExporter(context.Background(), event.NewExporter(h, eventtest.ExporterOptions()))
+}
+
+func BenchmarkJSONLogDiscard(b *testing.B) {
+	eventtest.RunBenchmark(b, jsonPrint(io.Discard), jsonLog)
+}
+
+func BenchmarkJSONLogfDiscard(b *testing.B) {
+	eventtest.RunBenchmark(b, jsonPrint(io.Discard), jsonLogf)
+}
+
+func TestLogJSON(t *testing.T) {
+	eventtest.TestBenchmark(t, jsonPrint, jsonLog, `
+{"time":"2020/03/05 14:27:48","level":"info","A":0,"msg":"a"}
+{"time":"2020/03/05 14:27:49","level":"info","B":"A value","msg":"b"}
+{"time":"2020/03/05 14:27:50","level":"info","A":1,"msg":"a"}
+{"time":"2020/03/05 14:27:51","level":"info","B":"Some other value","msg":"b"}
+{"time":"2020/03/05 14:27:52","level":"info","A":22,"msg":"a"}
+{"time":"2020/03/05 14:27:53","level":"info","B":"Some other value","msg":"b"}
+{"time":"2020/03/05 14:27:54","level":"info","A":333,"msg":"a"}
+{"time":"2020/03/05 14:27:55","level":"info","B":" ","msg":"b"}
+{"time":"2020/03/05 14:27:56","level":"info","A":4444,"msg":"a"}
+{"time":"2020/03/05 14:27:57","level":"info","B":"prime count of values","msg":"b"}
+{"time":"2020/03/05 14:27:58","level":"info","A":55555,"msg":"a"}
+{"time":"2020/03/05 14:27:59","level":"info","B":"V","msg":"b"}
+{"time":"2020/03/05 14:28:00","level":"info","A":666666,"msg":"a"}
+{"time":"2020/03/05 14:28:01","level":"info","B":"A value","msg":"b"}
+{"time":"2020/03/05 14:28:02","level":"info","A":7777777,"msg":"a"}
+{"time":"2020/03/05 14:28:03","level":"info","B":"A value","msg":"b"}
+`)
+}
+
+func TestLogfJSON(t *testing.T) {
+	eventtest.TestBenchmark(t, jsonPrint, jsonLogf, `
+{"time":"2020/03/05 14:27:48","level":"info","msg":"a where A=0"}
+{"time":"2020/03/05 14:27:49","level":"info","msg":"b where B=\"A value\""}
+{"time":"2020/03/05 14:27:50","level":"info","msg":"a where A=1"}
+{"time":"2020/03/05 14:27:51","level":"info","msg":"b where B=\"Some other value\""}
+{"time":"2020/03/05 14:27:52","level":"info","msg":"a where A=22"}
+{"time":"2020/03/05 14:27:53","level":"info","msg":"b where B=\"Some other value\""}
+{"time":"2020/03/05 14:27:54","level":"info","msg":"a where A=333"}
+{"time":"2020/03/05 14:27:55","level":"info","msg":"b where B=\" \""}
+{"time":"2020/03/05 14:27:56","level":"info","msg":"a where A=4444"}
+{"time":"2020/03/05 14:27:57","level":"info","msg":"b where B=\"prime count of values\""}
+{"time":"2020/03/05 14:27:58","level":"info","msg":"a where A=55555"}
+{"time":"2020/03/05 14:27:59","level":"info","msg":"b where B=\"V\""}
+{"time":"2020/03/05 14:28:00","level":"info","msg":"a where A=666666"}
+{"time":"2020/03/05 14:28:01","level":"info","msg":"b where B=\"A value\""}
+{"time":"2020/03/05 14:28:02","level":"info","msg":"a where A=7777777"}
+{"time":"2020/03/05 14:28:03","level":"info","msg":"b where B=\"A value\""}
+`)
+}
@@ -0,0 +1,386 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package json provides a handler that prints events as JSON objects,
+// one per line.
+package json
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/exp/event"
+)
+
+// TODO: some actual research into what this arbritray optimization number should be
+const bufCap = 50
+
+// Keys holds the JSON object keys used for the event metadata.
+// An empty key selects the default, and a key of "-" omits that field.
+type Keys struct {
+	Time      string // default "time"
+	ID        string // default "id"
+	Parent    string // default "parent"
+	Kind      string // default "kind"
+	Namespace string // default "in"
+	Owner     string // default "owner"
+	Name      string // default "name"
+}
+
+type Printer struct {
+	Keys Keys
+	// TimeFormat is the layout used for the time field.
+	// If empty, time.RFC3339Nano is used.
+	TimeFormat string
+
+	buf     [bufCap]byte
+	quote   [6]byte // separate from buf, which may hold the text being quoted
+	needSep bool
+	w       bytes.Buffer
+}
+
+type Handler struct {
+	to io.Writer
+	Printer
+}
+
+// NewHandler returns a handler that prints the events to the supplied writer.
+// Each event is printed as a JSON object on a single line.
+func NewHandler(to io.Writer) *Handler {
+	return &Handler{to: to}
+}
+
+func (h *Handler) Event(ctx context.Context, ev *event.Event) context.Context {
+	h.Printer.Event(h.to, ev)
+	return ctx
+}
+
+// Event writes ev to w as a single line JSON object.
+// The metadata fields are written first, followed by the labels in order.
+func (p *Printer) Event(w io.Writer, ev *event.Event) {
+	p.needSep = false
+	io.WriteString(w, "{")
+	if !ev.At.IsZero() {
+		if p.field(w, p.Keys.Time, "time") {
+			format := p.TimeFormat
+			if format == "" {
+				format = time.RFC3339Nano
+			}
+			p.bytes(w, ev.At.AppendFormat(p.buf[:0], format))
+		}
+	}
+	if ev.ID != 0 && p.field(w, p.Keys.ID, "id") {
+		w.Write(strconv.AppendUint(p.buf[:0], ev.ID, 10))
+	}
+	if ev.Parent != 0 && p.field(w, p.Keys.Parent, "parent") {
+		w.Write(strconv.AppendUint(p.buf[:0], ev.Parent, 10))
+	}
+	if ev.Kind != 0 && p.field(w, p.Keys.Kind, "kind") {
+		p.string(w, ev.Kind.String())
+	}
+	if ev.Source.Space != "" && p.field(w, p.Keys.Namespace, "in") {
+		p.string(w, ev.Source.Space)
+	}
+	if ev.Source.Owner != "" && p.field(w, p.Keys.Owner, "owner") {
+		p.string(w, ev.Source.Owner)
+	}
+	if ev.Source.Name != "" && p.field(w, p.Keys.Name, "name") {
+		p.string(w, ev.Source.Name)
+	}
+	for _, l := range ev.Labels {
+		p.Label(w, l)
+	}
+	io.WriteString(w, "}\n")
+}
+
+// field writes the separator and key for a metadata field.
+// It returns false if the field has been disabled.
+func (p *Printer) field(w io.Writer, key, def string) bool {
+	switch key {
+	case "-":
+		return false
+	case "":
+		key = def
+	}
+	p.key(w, key)
+	return true
+}
+
+func (p *Printer) key(w io.Writer, key string) {
+	if p.needSep {
+		io.WriteString(w, ",")
+	}
+	p.needSep = true
+	p.string(w, key)
+	io.WriteString(w, ":")
+}
+
+// Label writes a label as a member of the current JSON object.
+// Groups are written as nested objects. Labels with no name are skipped.
+func (p *Printer) Label(w io.Writer, l event.Label) {
+	if l.Name == "" {
+		return
+	}
+	p.key(w, l.Name)
+	p.value(w, l)
+}
+
+func (p *Printer) value(w io.Writer, l event.Label) {
+	switch {
+	case !l.HasValue():
+		io.WriteString(w, "null")
+	case l.IsString():
+		p.string(w, l.String())
+	case l.IsBytes():
+		p.bytes(w, l.Bytes())
+	case l.IsInt64():
+		w.Write(strconv.AppendInt(p.buf[:0], l.Int64(), 10))
+	case l.IsUint64():
+		w.Write(strconv.AppendUint(p.buf[:0], l.Uint64(), 10))
+	case l.IsFloat64():
+		p.float(w, l.Float64())
+	case l.IsBool():
+		p.bool(w, l.Bool())
+	case l.IsDuration():
+		p.string(w, l.Duration().String())
+	case l.IsGroup():
+		io.WriteString(w, "{")
+		p.needSep = false
+		for _, child := range l.Group() {
+			p.Label(w, child)
+		}
+		io.WriteString(w, "}")
+		p.needSep = true
+	default:
+		p.any(w, l.Interface())
+	}
+}
+
+// any writes a value that was stored with event.Value.
+// Common types are handled directly, anything else is printed in its
+// fmt.Print form as a JSON string.
+func (p *Printer) any(w io.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		io.WriteString(w, "null")
+	case string:
+		p.string(w, v)
+	case []byte:
+		p.bytes(w, v)
+	case bool:
+		p.bool(w, v)
+	case int:
+		w.Write(strconv.AppendInt(p.buf[:0], int64(v), 10))
+	case int8:
+		w.Write(strconv.AppendInt(p.buf[:0], int64(v), 10))
+	case int16:
+		w.Write(strconv.AppendInt(p.buf[:0], int64(v), 10))
+	case int32:
+		w.Write(strconv.AppendInt(p.buf[:0], int64(v), 10))
+	case int64:
+		w.Write(strconv.AppendInt(p.buf[:0], v, 10))
+	case uint:
+		w.Write(strconv.AppendUint(p.buf[:0], uint64(v), 10))
+	case uint8:
+		w.Write(strconv.AppendUint(p.buf[:0], uint64(v), 10))
+	case uint16:
+		w.Write(strconv.AppendUint(p.buf[:0], uint64(v), 10))
+	case uint32:
+		w.Write(strconv.AppendUint(p.buf[:0], uint64(v), 10))
+	case uint64:
+		w.Write(strconv.AppendUint(p.buf[:0], v, 10))
+	case float32:
+		p.float(w, float64(v))
+	case float64:
+		p.float(w, v)
+	case time.Duration:
+		p.string(w, v.String())
+	case time.Time:
+		format := p.TimeFormat
+		if format == "" {
+			format = time.RFC3339Nano
+		}
+		p.bytes(w, v.AppendFormat(p.buf[:0], format))
+	case event.Stack:
+		p.stack(w, v)
+	case event.Causes:
+		p.causes(w, v)
+	case interface{ MarshalJSON() ([]byte, error) }:
+		if nilPointer(v) {
+			io.WriteString(w, "null")
+			return
+		}
+		b, err := v.MarshalJSON()
+		if err == nil {
+			if p.w.Cap() == 0 {
+				p.w = *bytes.NewBuffer(p.buf[:0])
+			}
+			// compact the output so that the event stays on one line
+			err = json.Compact(&p.w, b)
+		}
+		if err != nil {
+			p.w.Reset()
+			p.string(w, err.Error())
+			return
+		}
+		w.Write(p.w.Bytes())
+		p.w.Reset()
+	case error:
+		if nilPointer(v) {
+			io.WriteString(w, "null")
+			return
+		}
+		p.string(w, v.Error())
+	case fmt.Stringer:
+		if nilPointer(v) {
+			io.WriteString(w, "null")
+			return
+		}
+		p.string(w, v.String())
+	default:
+		if p.w.Cap() == 0 {
+			// we rely on the inliner to cause this to not allocate
+			p.w = *bytes.NewBuffer(p.buf[:0])
+		}
+		fmt.Fprint(&p.w, v)
+		b := p.w.Bytes()
+		p.w.Reset()
+		p.bytes(w, b)
+	}
+}
+
+// nilPointer reports whether v is a nil pointer, whose methods may panic if
+// they have value receivers.
+func nilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// stack writes s as an array of "function file:line" strings.
+func (p *Printer) stack(w io.Writer, s event.Stack) {
+	io.WriteString(w, "[")
+	for i, f := range s.Frames() {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		p.string(w, f.Function+" "+f.File+":"+strconv.Itoa(f.Line))
+	}
+	io.WriteString(w, "]")
+}
+
+// causes writes c as an array of objects holding the type and message of each
+// error in the chain.
+func (p *Printer) causes(w io.Writer, c event.Causes) {
+	io.WriteString(w, "[")
+	for i, err := range c {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, `{"type":`)
+		p.string(w, fmt.Sprintf("%T", err))
+		io.WriteString(w, `,"msg":`)
+		p.string(w, err.Error())
+		io.WriteString(w, "}")
+	}
+	io.WriteString(w, "]")
+}
+
+func (p *Printer) bool(w io.Writer, b bool) {
+	if b {
+		io.WriteString(w, "true")
+	} else {
+		io.WriteString(w, "false")
+	}
+}
+
+// float writes a number, or a string for the values JSON cannot represent.
+func (p *Printer) float(w io.Writer, f float64) {
+	switch {
+	case math.IsNaN(f):
+		io.WriteString(w, `"NaN"`)
+	case math.IsInf(f, 1):
+		io.WriteString(w, `"+Inf"`)
+	case math.IsInf(f, -1):
+		io.WriteString(w, `"-Inf"`)
+	default:
+		w.Write(strconv.AppendFloat(p.buf[:0], f, 'g', -1, 64))
+	}
+}
+
+// string writes s as a quoted JSON string.
+func (p *Printer) string(w io.Writer, s string) {
+	io.WriteString(w, `"`)
+	written := 0
+	for offset := 0; offset < len(s); {
+		r, size := utf8.DecodeRuneInString(s[offset:])
+		q := p.quoteRune(r, size)
+		if len(q) == 0 {
+			offset += size
+			continue
+		}
+		io.WriteString(w, s[written:offset])
+		w.Write(q)
+		offset += size
+		written = offset
+	}
+	io.WriteString(w, s[written:])
+	io.WriteString(w, `"`)
+}
+
+// bytes writes buf as a quoted JSON string.
+func (p *Printer) bytes(w io.Writer, buf []byte) {
+	io.WriteString(w, `"`)
+	written := 0
+	for offset := 0; offset < len(buf); {
+		r, size := utf8.DecodeRune(buf[offset:])
+		q := p.quoteRune(r, size)
+		if len(q) == 0 {
+			offset += size
+			continue
+		}
+		w.Write(buf[written:offset])
+		w.Write(q)
+		offset += size
+		written = offset
+	}
+	w.Write(buf[written:])
+	io.WriteString(w, `"`)
+}
+
+const hex = "0123456789abcdef"
+
+// quoteRune returns the escaped form of r, or nil if it needs no escaping.
+// The returned slice is only valid until the next call.
+func (p *Printer) quoteRune(r rune, size int) []byte {
+	switch r {
+	case '"':
+		return append(p.quote[:0], `\"`...)
+	case '\\':
+		return append(p.quote[:0], `\\`...)
+	case '\n':
+		return append(p.quote[:0], `\n`...)
+	case '\r':
+		return append(p.quote[:0], `\r`...)
+	case '\t':
+		return append(p.quote[:0], `\t`...)
+	case '\u2028', '\u2029':
+		// valid JSON, but not valid JavaScript
+		return append(p.quote[:0], '\\', 'u', '2', '0', '2', hex[r&0xF])
+	}
+	switch {
+	case r < ' ':
+		return append(p.quote[:0], '\\', 'u', '0', '0', hex[r>>4], hex[r&0xF])
+	case r == utf8.RuneError && size == 1:
+		return append(p.quote[:0], `\ufffd`...)
+	}
+	return nil
+}
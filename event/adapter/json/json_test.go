@@ -0,0 +1,206 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/event"
+	ejson "golang.org/x/exp/event/adapter/json"
+	"golang.org/x/exp/event/severity"
+)
+
+// point and failure have value receivers, which panic on nil pointers.
+type point struct{ X, Y int }
+
+func (p point) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`[%d, %d]`, p.X, p.Y)), nil
+}
+
+type failure struct{ msg string }
+
+func (f failure) Error() string { return f.msg }
+
+func TestPrint(t *testing.T) {
+	var p ejson.Printer
+	buf := &strings.Builder{}
+	at, _ := time.Parse(time.RFC3339, "2020-03-05T14:27:48Z")
+	for _, test := range []struct {
+		name   string
+		event  event.Event
+		expect string
+	}{{
+		name:   "empty",
+		event:  event.Event{},
+		expect: `{}`,
+	}, {
+		name:   "span",
+		event:  event.Event{ID: 34, Kind: event.StartKind},
+		expect: `{"id":34,"kind":"start"}`,
+	}, {
+		name:   "parent",
+		event:  event.Event{Parent: 14, Kind: event.EndKind},
+		expect: `{"parent":14,"kind":"end"}`,
+	}, {
+		name: "source",
+		event: event.Event{Source: event.Source{
+			Space: "golang.org/x/exp/event",
+			Owner: "Printer",
+			Name:  "Event",
+		}},
+		expect: `{"in":"golang.org/x/exp/event","owner":"Printer","name":"Event"}`,
+	}, {
+		name:   "at",
+		event:  event.Event{At: at},
+		expect: `{"time":"2020-03-05T14:27:48Z"}`,
+	}, {
+		name:   "message",
+		event:  event.Event{Labels: []event.Label{event.String("msg", "a message")}},
+		expect: `{"msg":"a message"}`,
+	}, {
+		name: "scalars",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Int64("i", -67),
+				event.Uint64("u", 67),
+				event.Float64("f", 263.2),
+				event.Bool("t", true),
+				event.Bool("f", false),
+			},
+		},
+		expect: `{"i":-67,"u":67,"f":263.2,"t":true,"f":false}`,
+	}, {
+		name: "bad floats",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Float64("nan", math.NaN()),
+				event.Float64("inf", math.Inf(1)),
+				event.Float64("-inf", math.Inf(-1)),
+			},
+		},
+		expect: `{"nan":"NaN","inf":"+Inf","-inf":"-Inf"}`,
+	}, {
+		name: "bytes and duration",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Bytes("b", []byte(`bytes "need" quote`)),
+				event.Duration("d", 1500*time.Millisecond),
+			},
+		},
+		expect: `{"b":"bytes \"need\" quote","d":"1.5s"}`,
+	}, {
+		name: "values",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Value("nil", nil),
+				event.Value("int", 17),
+				event.Value("string", "R"),
+				event.Value("level", severity.Info),
+				event.Value("error", errors.New("failed")),
+				event.Value("raw", json.RawMessage(`{"a":1}`)),
+				event.Value("other", struct{ A int }{3}),
+			},
+		},
+		expect: `{"nil":null,"int":17,"string":"R","level":"info","error":"failed","raw":{"a":1},"other":"{3}"}`,
+	}, {
+		name: "marshalers",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Value("indented", json.RawMessage("{\n  \"a\": [1, 2]\n}")),
+				event.Value("invalid", json.RawMessage("{\n")),
+				event.Value("point", point{1, 2}),
+				event.Value("nil", (*point)(nil)),
+				event.Value("nil error", (*failure)(nil)),
+			},
+		},
+		expect: `{"indented":{"a":[1,2]},"invalid":"unexpected end of JSON input","point":[1,2],"nil":null,"nil error":null}`,
+	}, {
+		name: "empty label",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Value("before", nil),
+				event.String("", "text"),
+				event.Value("after", nil),
+			},
+		},
+		expect: `{"before":null,"after":null}`,
+	}, {
+		name:   "quoting",
+		event:  event.Event{Labels: []event.Label{event.String("k\"ey", "a\\b\n\t\x01\u2028")}},
+		expect: `{"k\"ey":"a\\b\n\t\u0001\u2028"}`,
+	}, {
+		name:   "invalid utf8",
+		event:  event.Event{Labels: []event.Label{event.Bytes("b", []byte("a\xffb"))}},
+		expect: `{"b":"a\ufffdb"}`,
+	}, {
+		name: "group",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Group("http",
+					event.Group("request", event.String("method", "GET")),
+					event.Int64("status", 200),
+				),
+				event.Group("empty"),
+				event.String("msg", "done"),
+			},
+		},
+		expect: `{"http":{"request":{"method":"GET"},"status":200},"empty":{},"msg":"done"}`,
+	}, {
+		name: "error causes",
+		event: event.Event{
+			Labels: []event.Label{
+				event.ErrorCauses.Of(event.ErrorChain(fmt.Errorf("wrapped: %w", io.EOF))),
+			},
+		},
+		expect: `{"causes":[{"type":"*fmt.wrapError","msg":"wrapped: EOF"},{"type":"*errors.errorString","msg":"EOF"}]}`,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			buf.Reset()
+			p.Event(buf, &test.event)
+			got := strings.TrimSpace(buf.String())
+			if got != test.expect {
+				t.Errorf("got: \n%s\nexpect:\n%s\n", got, test.expect)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("invalid JSON: %s", got)
+			}
+		})
+	}
+}
+
+func TestPrinterKeys(t *testing.T) {
+	ev := event.Event{
+		ID:     3,
+		Parent: 2,
+		Kind:   event.LogKind,
+		At:     time.Date(2020, 3, 5, 14, 27, 48, 0, time.UTC),
+		Source: event.Source{Space: "golang.org/x/exp/event"},
+		Labels: []event.Label{event.String("msg", "some text")},
+	}
+	p := ejson.Printer{
+		Keys: ejson.Keys{
+			Time:      "ts",
+			ID:        "-",
+			Parent:    "parent_id",
+			Kind:      "-",
+			Namespace: "logger",
+		},
+		TimeFormat: time.Kitchen,
+	}
+	buf := &strings.Builder{}
+	p.Event(buf, &ev)
+	got := strings.TrimSpace(buf.String())
+	want := `{"ts":"2:27PM","parent_id":2,"logger":"golang.org/x/exp/event","msg":"some text"}`
+	if got != want {
+		t.Errorf("got: \n%s\nexpect:\n%s\n", got, want)
+	}
+}
@@ -27,6 +27,7 @@ type Printer struct {
 	buf               [bufCap]byte
 	needSep           bool
 	w                 bytes.Buffer
+	prefix            []byte // dotted names of the enclosing groups
 }
 
 type Handler struct {
@@ -97,12 +98,25 @@ func (p *Printer) separator(w io.Writer) {
 	p.needSep = true
 }
 
+// Label prints a single label.
+// The labels of a group are printed in turn, with their names prefixed by the
+// name of the group and a dot.
 func (p *Printer) Label(w io.Writer, l event.Label) {
 	if l.Name == "" {
 		return
 	}
+	if l.IsGroup() {
+		n := len(p.prefix)
+		p.prefix = append(p.prefix, l.Name...)
+		p.prefix = append(p.prefix, '.')
+		for _, child := range l.Group() {
+			p.Label(w, child)
+		}
+		p.prefix = p.prefix[:n]
+		return
+	}
 	p.separator(w)
-	p.Ident(w, l.Name)
+	p.key(w, l.Name)
 	if l.HasValue() {
 		io.WriteString(w, "=")
 		switch {
@@ -143,6 +157,23 @@ func (p *Printer) Label(w io.Writer, l event.Label) {
 	}
 }
 
+// key prints a label name, prefixed by the names of the enclosing groups.
+func (p *Printer) key(w io.Writer, name string) {
+	if len(p.prefix) == 0 {
+		p.Ident(w, name)
+		return
+	}
+	if !stringNeedQuote(string(p.prefix)) && !stringNeedQuote(name) {
+		w.Write(p.prefix)
+		io.WriteString(w, name)
+		return
+	}
+	io.WriteString(w, `"`)
+	p.escapeBytes(w, p.prefix)
+	p.escapeString(w, name)
+	io.WriteString(w, `"`)
+}
+
 func (p *Printer) Ident(w io.Writer, s string) {
 	if !stringNeedQuote(s) {
 		io.WriteString(w, s)
@@ -161,6 +192,11 @@ func (p *Printer) string(w io.Writer, s string) {
 
 func (p *Printer) quoteString(w io.Writer, s string) {
 	io.WriteString(w, `"`)
+	p.escapeString(w, s)
+	io.WriteString(w, `"`)
+}
+
+func (p *Printer) escapeString(w io.Writer, s string) {
 	written := 0
 	for offset, r := range s {
 		q := quoteRune(r)
@@ -174,7 +210,6 @@ func (p *Printer) quoteString(w io.Writer, s string) {
 		io.WriteString(w, q)
 	}
 	io.WriteString(w, s[written:])
-	io.WriteString(w, `"`)
 }
 
 func (p *Printer) bytes(w io.Writer, buf []byte) {
@@ -188,6 +223,11 @@ func (p *Printer) bytes(w io.Writer, buf []byte) {
 // Bytes writes a byte array in string form to the printer.
 func (p *Printer) quoteBytes(w io.Writer, buf []byte) {
 	io.WriteString(w, `"`)
+	p.escapeBytes(w, buf)
+	io.WriteString(w, `"`)
+}
+
+func (p *Printer) escapeBytes(w io.Writer, buf []byte) {
 	written := 0
 	for offset := 0; offset < len(buf); {
 		r, size := utf8.DecodeRune(buf[offset:])
@@ -203,7 +243,6 @@ func (p *Printer) quoteBytes(w io.Writer, buf []byte) {
 		io.WriteString(w, q)
 	}
 	w.Write(buf[written:])
-	io.WriteString(w, `"`)
 }
 
 // time writes a timstamp in the same format as
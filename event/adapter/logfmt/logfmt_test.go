@@ -5,6 +5,8 @@
 package logfmt_test
 
 import (
+	"fmt"
+	"io"
 	"strings"
 	"testing"
 	"time"
@@ -132,6 +134,34 @@ func TestPrint(t *testing.T) {
 			},
 		},
 		expect: `value="bytes \"need\" quote"`,
+	}, {
+		name: "group",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Group("http",
+					event.Group("request", event.String("method", "GET")),
+					event.Int64("status", 200),
+				),
+				event.String("msg", "done"),
+			},
+		},
+		expect: `http.request.method=GET http.status=200 msg=done`,
+	}, {
+		name: "quoted group",
+		event: event.Event{
+			Labels: []event.Label{
+				event.Group("a b", event.String("c", "d")),
+			},
+		},
+		expect: `"a b.c"=d`,
+	}, {
+		name: "error causes",
+		event: event.Event{
+			Labels: []event.Label{
+				event.ErrorCauses.Of(event.ErrorChain(fmt.Errorf("wrapped: %w", io.EOF))),
+			},
+		},
+		expect: `causes=*fmt.wrapError,*errors.errorString`,
 	}} {
 		t.Run(test.name, func(t *testing.T) {
 			buf.Reset()
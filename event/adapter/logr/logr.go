@@ -69,7 +69,6 @@ func (l *logSink) Error(err error, msg string, keysAndValues ...interface{}) {
 }
 
 func (l *logSink) log(ev *event.Event, msg string, keysAndValues []interface{}) {
-	ev.Labels = append(ev.Labels)
 	ev.Labels = append(ev.Labels, l.labels...)
 	for i := 0; i < len(keysAndValues); i += 2 {
 		ev.Labels = append(ev.Labels, newLabel(keysAndValues[i], keysAndValues[i+1]))
@@ -94,7 +93,12 @@ func (l *logSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
 	return &l2
 }
 
+// newLabel returns a label for a key/value pair.
+// Values of type map[string]interface{} become groups.
 func newLabel(key, value interface{}) event.Label {
+	if m, ok := value.(map[string]interface{}); ok {
+		return event.Map(key.(string), m)
+	}
 	return event.Value(key.(string), value)
 }
 
@@ -36,3 +36,23 @@ func TestInfo(t *testing.T) {
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
 	}
 }
+
+func TestGroups(t *testing.T) {
+	ctx, th := eventtest.NewCapture()
+	log := elogr.NewLogger(ctx, "/").WithValues("http", map[string]interface{}{"method": "GET"})
+	log.Info("mess", "status", 200)
+	want := []event.Event{{
+		ID:   1,
+		Kind: event.LogKind,
+		Labels: []event.Label{
+			severity.Level(0).Label(),
+			event.Group("http", event.Value("method", "GET")),
+			event.Value("status", 200),
+			event.String("name", ""),
+			event.String("msg", "mess"),
+		},
+	}}
+	if diff := cmp.Diff(want, th.Got, eventtest.CmpOptions()...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -55,13 +55,26 @@ func (f *formatter) Format(e *logrus.Entry) ([]byte, error) {
 	ev.At = e.Time
 	ev.Labels = append(ev.Labels, convertLevel(e.Level).Label())
 	for k, v := range e.Data {
-		ev.Labels = append(ev.Labels, event.Value(k, v))
+		ev.Labels = append(ev.Labels, newLabel(k, v))
 	}
 	ev.Labels = append(ev.Labels, event.String("msg", e.Message))
 	ev.Deliver()
 	return nil, nil
 }
 
+// newLabel returns a label for a logrus field.
+// Nested fields become groups.
+func newLabel(key string, value interface{}) event.Label {
+	switch v := value.(type) {
+	case logrus.Fields:
+		return event.Map(key, v)
+	case map[string]interface{}:
+		return event.Map(key, v)
+	default:
+		return event.Value(key, value)
+	}
+}
+
 func convertLevel(level logrus.Level) severity.Level {
 	switch level {
 	case logrus.PanicLevel:
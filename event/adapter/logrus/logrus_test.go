@@ -42,3 +42,24 @@ func Test(t *testing.T) {
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
 	}
 }
+
+func TestGroups(t *testing.T) {
+	ctx, th := eventtest.NewCapture()
+	log := logrus.New()
+	log.SetFormatter(elogrus.NewFormatter())
+	log.SetOutput(io.Discard)
+	log.WithContext(ctx).WithField("http", logrus.Fields{"method": "GET", "status": 200}).Info("mess")
+
+	want := []event.Event{{
+		ID:   1,
+		Kind: event.LogKind,
+		Labels: []event.Label{
+			severity.Info.Label(),
+			event.Group("http", event.Value("method", "GET"), event.Value("status", 200)),
+			event.String("msg", "mess"),
+		},
+	}}
+	if diff := cmp.Diff(want, th.Got, eventtest.CmpOptions()...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+// Package stdlib provides a writer for the standard library log package that
+// delivers events.
+// To use globally:
+//
+//	log.SetFlags(0)
+//	log.SetOutput(stdlib.NewWriter(ctx))
+//
+// and for a Logger instance:
+//
+//	logger := stdlib.NewLogger(ctx)
+//
+// The log and stdlib packages are registered as event helpers, so events get
+// the source of the code calling the log functions when namespaces are
+// enabled on the exporter.
+package stdlib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/severity"
+)
+
+func init() {
+	event.RegisterHelper("log")
+	event.RegisterHelper("golang.org/x/exp/event/adapter/stdlib")
+}
+
+type writer struct {
+	ctx context.Context
+}
+
+// NewWriter returns a writer that delivers each line written by a log.Logger
+// to the exporter found in ctx, as an info level log event.
+// The whole line is used as the message, so the logger should normally have
+// no flags set, as the event carries its own time.
+func NewWriter(ctx context.Context) io.Writer {
+	return &writer{ctx: ctx}
+}
+
+// NewLogger returns a log.Logger that writes to NewWriter(ctx), with no
+// prefix or flags.
+func NewLogger(ctx context.Context) *log.Logger {
+	return log.New(NewWriter(ctx), "", 0)
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	ev := event.New(w.ctx, event.LogKind)
+	if ev == nil {
+		return len(p), nil
+	}
+	ev.Labels = append(ev.Labels, severity.Info.Label())
+	ev.Labels = append(ev.Labels, event.String("msg", string(bytes.TrimSuffix(p, []byte("\n")))))
+	ev.Deliver()
+	return len(p), nil
+}
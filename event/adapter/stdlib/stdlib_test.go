@@ -0,0 +1,83 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package stdlib_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/adapter/logfmt"
+	"golang.org/x/exp/event/adapter/stdlib"
+	"golang.org/x/exp/event/eventtest"
+	"golang.org/x/exp/event/severity"
+)
+
+func Test(t *testing.T) {
+	ctx, h := eventtest.NewCapture()
+	log := stdlib.NewLogger(ctx)
+	log.Printf("mess %d", 17)
+	log.Println("multi\nline")
+	want := []event.Event{{
+		ID:   1,
+		Kind: event.LogKind,
+		Labels: []event.Label{
+			severity.Info.Label(),
+			event.String("msg", "mess 17"),
+		},
+	}, {
+		ID:   2,
+		Kind: event.LogKind,
+		Labels: []event.Label{
+			severity.Info.Label(),
+			event.String("msg", "multi\nline"),
+		},
+	}}
+	if diff := cmp.Diff(want, h.Got, eventtest.CmpOptions()...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestSource(t *testing.T) {
+	h := &eventtest.CaptureHandler{}
+	opts := eventtest.ExporterOptions()
+	opts.EnableNamespaces = true
+	ctx := event.WithExporter(context.Background(), event.NewExporter(h, opts))
+
+	logger := stdlib.NewLogger(ctx)
+	logger.Print("method")
+	out, flags := log.Writer(), log.Flags()
+	defer func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	}()
+	log.SetOutput(stdlib.NewWriter(ctx))
+	log.SetFlags(0)
+	log.Print("global")
+
+	want := event.Source{Space: "golang.org/x/exp/event/adapter/stdlib_test", Name: "TestSource"}
+	if len(h.Got) != 2 {
+		t.Fatalf("got %d events, want 2", len(h.Got))
+	}
+	for _, ev := range h.Got {
+		if ev.Source != want {
+			t.Errorf("%v: got source %+v, want %+v", ev.Find("msg"), ev.Source, want)
+		}
+	}
+}
+
+func stdlibEvent(w io.Writer) context.Context {
+	ctx := event.WithExporter(context.Background(), event.NewExporter(logfmt.NewHandler(w), eventtest.ExporterOptions()))
+	return context.WithValue(ctx, stdlibLogKey{}, stdlib.NewLogger(ctx))
+}
+
+func TestLogEvent(t *testing.T) {
+	eventtest.TestBenchmark(t, stdlibEvent, stdlibLog, eventtest.LogfOutput)
+}
@@ -29,6 +29,9 @@ import (
 type core struct {
 	ev     *event.Event // cloned but never delivered
 	labels []event.Label
+	// nsFields holds the fields added by With from the first namespace on.
+	// They are converted when writing, as they also enclose the written fields.
+	nsFields []zapcore.Field
 }
 
 var _ zapcore.Core = (*core)(nil)
@@ -43,10 +46,20 @@ func (c *core) Enabled(level zapcore.Level) bool {
 
 func (c *core) With(fields []zapcore.Field) zapcore.Core {
 	c2 := *c
+	if len(c.nsFields) > 0 {
+		c2.nsFields = make([]zapcore.Field, len(c.nsFields), len(c.nsFields)+len(fields))
+		copy(c2.nsFields, c.nsFields)
+		c2.nsFields = append(c2.nsFields, fields...)
+		return &c2
+	}
 	if len(fields) > 0 {
 		c2.labels = make([]event.Label, len(c.labels), len(c.labels)+len(fields))
 		copy(c2.labels, c.labels)
-		for _, f := range fields {
+		for i, f := range fields {
+			if f.Type == zapcore.NamespaceType {
+				c2.nsFields = append([]zapcore.Field(nil), fields[i:]...)
+				break
+			}
 			c2.labels = append(c2.labels, newLabel(f))
 		}
 	}
@@ -73,9 +86,10 @@ func (c *core) Write(e zapcore.Entry, fs []zapcore.Field) error {
 	if e.Caller.Defined {
 		ev.Labels = append(ev.Labels, event.String("caller", e.Caller.String()))
 	}
-	for _, f := range fs {
-		ev.Labels = append(ev.Labels, newLabel(f))
+	if len(c.nsFields) > 0 {
+		fs = append(c.nsFields[:len(c.nsFields):len(c.nsFields)], fs...)
 	}
+	ev.Labels = appendFields(ev.Labels, fs)
 	ev.Labels = append(ev.Labels, event.String("msg", e.Message))
 	ev.Deliver()
 	return nil
@@ -87,9 +101,27 @@ func (c *core) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.Checked
 
 func (c *core) Sync() error { return nil }
 
+// appendFields appends a label for each field to labels.
+// A namespace field puts all the fields that follow it into a group.
+func appendFields(labels []event.Label, fs []zapcore.Field) []event.Label {
+	for i, f := range fs {
+		if f.Type == zapcore.NamespaceType {
+			return append(labels, event.Group(f.Key, appendFields(nil, fs[i+1:])...))
+		}
+		labels = append(labels, newLabel(f))
+	}
+	return labels
+}
+
 func newLabel(f zap.Field) event.Label {
 	switch f.Type {
-	case zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.BinaryType, zapcore.ByteStringType,
+	case zapcore.ObjectMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		if err := f.Interface.(zapcore.ObjectMarshaler).MarshalLogObject(enc); err != nil {
+			return event.Value(f.Key, err)
+		}
+		return event.Map(f.Key, enc.Fields)
+	case zapcore.ArrayMarshalerType, zapcore.BinaryType, zapcore.ByteStringType,
 		zapcore.Complex128Type, zapcore.Complex64Type, zapcore.TimeFullType, zapcore.ReflectType,
 		zapcore.ErrorType:
 		return event.Value(f.Key, f.Interface)
@@ -124,7 +156,7 @@ func newLabel(f zap.Field) event.Label {
 	case zapcore.StringerType:
 		return event.String(f.Key, stringerToString(f.Interface))
 	case zapcore.NamespaceType:
-		// TODO: ???
+		// handled by appendFields
 		return event.Label{}
 	case zapcore.SkipType:
 		// TODO: avoid creating a label at all in this case.
@@ -11,6 +11,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"golang.org/x/exp/event"
 	ezap "golang.org/x/exp/event/adapter/zap"
 	"golang.org/x/exp/event/eventtest"
@@ -38,3 +39,39 @@ func Test(t *testing.T) {
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
 	}
 }
+
+func TestGroups(t *testing.T) {
+	ctx, h := eventtest.NewCapture()
+	log := zap.New(ezap.NewCore(ctx), zap.Fields(zap.String("resource", "R"), zap.Namespace("http")))
+	log.Info("mess", zap.Int("status", 200), zap.Object("request", request{method: "GET", path: "/"}))
+	want := []event.Event{{
+		ID:   1,
+		Kind: event.LogKind,
+		Labels: []event.Label{
+			event.String("resource", "R"),
+			severity.Info.Label(),
+			event.String("name", ""),
+			event.Group("http",
+				event.Int64("status", 200),
+				event.Group("request",
+					event.Value("method", "GET"),
+					event.Value("path", "/"),
+				),
+			),
+			event.String("msg", "mess"),
+		},
+	}}
+	if diff := cmp.Diff(want, h.Got, eventtest.CmpOptions()...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+type request struct {
+	method, path string
+}
+
+func (r request) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("method", r.method)
+	enc.AddString("path", r.path)
+	return nil
+}
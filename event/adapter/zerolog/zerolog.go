@@ -0,0 +1,261 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+// Package zerolog provides a zerolog writer that delivers events.
+// To use for a Logger instance:
+//
+//	logger := zerolog.New(ezerolog.NewWriter(ctx))
+//
+// Each zerolog event is parsed back into its fields, which become the labels
+// of a log event. The standard level, time, message and error fields are
+// recognized using the zerolog field name variables.
+package zerolog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/severity"
+)
+
+type writer struct {
+	ctx context.Context
+}
+
+var _ zerolog.LevelWriter = (*writer)(nil)
+
+// NewWriter returns a zerolog.LevelWriter that delivers each zerolog event
+// to the exporter found in ctx.
+func NewWriter(ctx context.Context) zerolog.LevelWriter {
+	return &writer{ctx: ctx}
+}
+
+// Write parses a single zerolog JSON event and delivers it.
+// The level is taken from the level field, if present.
+func (w *writer) Write(p []byte) (int, error) {
+	return w.write(p, zerolog.NoLevel, false)
+}
+
+// WriteLevel is like Write, but with the level supplied by zerolog.
+func (w *writer) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	return w.write(p, level, true)
+}
+
+func (w *writer) write(p []byte, level zerolog.Level, haveLevel bool) (int, error) {
+	ev := event.New(w.ctx, event.LogKind)
+	if ev == nil {
+		return len(p), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	labels, err := readEvent(dec)
+	if err != nil {
+		// deliver what we have rather than lose the event
+		ev.Labels = append(ev.Labels, event.Value(zerolog.ErrorFieldName, err), event.String("msg", string(bytes.TrimSpace(p))))
+		ev.Deliver()
+		return 0, err
+	}
+	var msg string
+	fields := labels[:0]
+	for _, l := range labels {
+		switch l.Name {
+		case zerolog.LevelFieldName:
+			if haveLevel {
+				continue
+			}
+			lvl, err := zerolog.ParseLevel(l.String())
+			if err != nil {
+				break
+			}
+			level = lvl
+			continue
+		case zerolog.TimestampFieldName:
+			if t, ok := parseTime(l); ok {
+				ev.At = t
+				continue
+			}
+		case zerolog.MessageFieldName:
+			msg = l.String()
+			continue
+		case zerolog.ErrorFieldName:
+			if l.IsString() {
+				l = event.Value(zerolog.ErrorFieldName, errors.New(l.String()))
+			}
+		}
+		fields = append(fields, l)
+	}
+	if level != zerolog.NoLevel {
+		ev.Labels = append(ev.Labels, convertLevel(level).Label())
+	}
+	ev.Labels = append(ev.Labels, fields...)
+	ev.Labels = append(ev.Labels, event.String("msg", msg))
+	ev.Deliver()
+	return len(p), nil
+}
+
+// readEvent reads a JSON object and returns its members as labels.
+func readEvent(dec *json.Decoder) ([]event.Label, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, fmt.Errorf("zerolog: expected object, got %v", tok)
+	}
+	return readLabels(dec)
+}
+
+// readLabels reads the members of an object whose opening brace has already
+// been read, converting each to a label.
+func readLabels(dec *json.Decoder) ([]event.Label, error) {
+	var labels []event.Label
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("zerolog: expected object key, got %v", tok)
+		}
+		l, err := readLabel(dec, key)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	if _, err := dec.Token(); err != nil { // closing brace
+		return nil, err
+	}
+	return labels, nil
+}
+
+// readLabel reads a value and returns it as a label.
+// Objects become groups.
+func readLabel(dec *json.Decoder, key string) (event.Label, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return event.Label{}, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '{' {
+			children, err := readLabels(dec)
+			if err != nil {
+				return event.Label{}, err
+			}
+			return event.Group(key, children...), nil
+		}
+		v, err := readArray(dec)
+		return event.Value(key, v), err
+	case string:
+		return event.String(key, tok), nil
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return event.Int64(key, i), nil
+		}
+		f, err := tok.Float64()
+		return event.Float64(key, f), err
+	case bool:
+		return event.Bool(key, tok), nil
+	default: // null
+		return event.Value(key, nil), nil
+	}
+}
+
+// readArray reads the elements of an array whose opening bracket has already
+// been read.
+func readArray(dec *json.Decoder) ([]interface{}, error) {
+	var values []interface{}
+	for dec.More() {
+		v, err := readAny(dec)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	if _, err := dec.Token(); err != nil { // closing bracket
+		return nil, err
+	}
+	return values, nil
+}
+
+func readAny(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+	if d == '[' {
+		return readArray(dec)
+	}
+	m := map[string]interface{}{}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		v, err := readAny(dec)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = v
+	}
+	_, err = dec.Token()
+	return m, err
+}
+
+// parseTime converts a time field written according to zerolog.TimeFieldFormat.
+func parseTime(l event.Label) (time.Time, bool) {
+	if l.IsString() {
+		t, err := time.Parse(zerolog.TimeFieldFormat, l.String())
+		return t, err == nil
+	}
+	if !l.IsInt64() {
+		return time.Time{}, false
+	}
+	v := l.Int64()
+	switch zerolog.TimeFieldFormat {
+	case zerolog.TimeFormatUnix:
+		return time.Unix(v, 0), true
+	case zerolog.TimeFormatUnixMs:
+		return time.Unix(0, v*int64(time.Millisecond)), true
+	case zerolog.TimeFormatUnixMicro:
+		return time.Unix(0, v*int64(time.Microsecond)), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func convertLevel(level zerolog.Level) severity.Level {
+	switch level {
+	case zerolog.TraceLevel:
+		return severity.Trace
+	case zerolog.DebugLevel:
+		return severity.Debug
+	case zerolog.InfoLevel:
+		return severity.Info
+	case zerolog.WarnLevel:
+		return severity.Warning
+	case zerolog.ErrorLevel:
+		return severity.Error
+	case zerolog.FatalLevel:
+		return severity.Fatal
+	case zerolog.PanicLevel:
+		return severity.Fatal + 1
+	default:
+		return severity.Info
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package zerolog_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/adapter/logfmt"
+	ezerolog "golang.org/x/exp/event/adapter/zerolog"
+	"golang.org/x/exp/event/eventtest"
+	"golang.org/x/exp/event/severity"
+)
+
+func Test(t *testing.T) {
+	ctx, h := eventtest.NewCapture()
+	log := zerolog.New(ezerolog.NewWriter(ctx)).With().Int("traceID", 17).Str("resource", "R").Logger()
+	log.Warn().
+		Float64("pi", 3.14).
+		Bool("ok", false).
+		Dict("http", zerolog.Dict().Str("method", "GET").Int("status", 200)).
+		Err(errors.New("failed")).
+		Msg("mess")
+	want := []event.Event{{
+		ID:   1,
+		Kind: event.LogKind,
+		Labels: []event.Label{
+			severity.Warning.Label(),
+			event.Int64("traceID", 17),
+			event.String("resource", "R"),
+			event.Float64("pi", 3.14),
+			event.Bool("ok", false),
+			event.Group("http", event.String("method", "GET"), event.Int64("status", 200)),
+			event.String("error", "failed"),
+			event.String("msg", "mess"),
+		},
+	}}
+	opts := append(eventtest.CmpOptions(), cmp.Transformer("error", func(l event.Label) event.Label {
+		if err, ok := l.Interface().(error); ok {
+			return event.String(l.Name, err.Error())
+		}
+		return l
+	}))
+	if diff := cmp.Diff(want, h.Got, opts...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	// Write without the level supplied by zerolog, as when wrapped by another writer.
+	defer func(format string) { zerolog.TimeFieldFormat = format }(zerolog.TimeFieldFormat)
+	zerolog.TimeFieldFormat = time.RFC3339
+	ctx, h := eventtest.NewCapture()
+	w := ezerolog.NewWriter(ctx)
+	io.WriteString(w, `{"level":"debug","time":"2020-03-05T14:27:48Z","n":1.5,"list":[1,"a"],"message":"mess"}`+"\n")
+	if len(h.Got) != 1 {
+		t.Fatalf("got %d events, want 1", len(h.Got))
+	}
+	ev := &h.Got[0]
+	if got, want := ev.At, eventtest.InitialTime; !got.Equal(want) {
+		t.Errorf("got time %v, want %v", got, want)
+	}
+	if got, want := ev.Find("list").String(), "[1 a]"; got != want {
+		t.Errorf("got list %s, want %s", got, want)
+	}
+	ev.Labels = append(ev.Labels[:2:2], ev.Labels[3:]...) // remove the list
+	want := []event.Event{{
+		ID:   1,
+		Kind: event.LogKind,
+		Labels: []event.Label{
+			severity.Debug.Label(),
+			event.Float64("n", 1.5),
+			event.String("msg", "mess"),
+		},
+	}}
+	if diff := cmp.Diff(want, h.Got, eventtest.CmpOptions()...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	h.Reset()
+	if _, err := io.WriteString(w, "not json\n"); err == nil {
+		t.Error("got no error for malformed input")
+	}
+	if len(h.Got) != 1 {
+		t.Errorf("got %d events for malformed input, want 1", len(h.Got))
+	}
+}
+
+func TestErrorFieldName(t *testing.T) {
+	defer func(name string) { zerolog.ErrorFieldName = name }(zerolog.ErrorFieldName)
+	zerolog.ErrorFieldName = "err"
+	ctx, h := eventtest.NewCapture()
+	w := ezerolog.NewWriter(ctx)
+	log := zerolog.New(w)
+	log.Error().Err(errors.New("failed")).Msg("mess")
+	if len(h.Got) != 1 {
+		t.Fatalf("got %d events, want 1", len(h.Got))
+	}
+	if err, ok := h.Got[0].Find("err").Interface().(error); !ok || err.Error() != "failed" {
+		t.Errorf("got err label %v, want the error", h.Got[0].Find("err"))
+	}
+
+	h.Reset()
+	io.WriteString(w, "not json\n")
+	if len(h.Got) != 1 {
+		t.Fatalf("got %d events for malformed input, want 1", len(h.Got))
+	}
+	if _, ok := h.Got[0].Find("err").Interface().(error); !ok {
+		t.Errorf("got err label %v for malformed input, want the error", h.Got[0].Find("err"))
+	}
+}
+
+func zerologEvent(w io.Writer) context.Context {
+	ctx := event.WithExporter(context.Background(), event.NewExporter(logfmt.NewHandler(w), eventtest.ExporterOptions()))
+	logger := zerolog.New(ezerolog.NewWriter(ctx))
+	return logger.WithContext(ctx)
+}
+
+func TestLogEvent(t *testing.T) {
+	eventtest.TestBenchmark(t, zerologEvent, zerologMsgf, eventtest.LogfOutput)
+}
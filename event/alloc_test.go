@@ -8,6 +8,7 @@ package event_test
 
 import (
 	"context"
+	"errors"
 	"io"
 	"testing"
 
@@ -19,6 +20,7 @@ import (
 func TestAllocs(t *testing.T) {
 	anInt := event.Int64("int", 4)
 	aString := event.String("string", "value")
+	anErr := errors.New("an error")
 
 	e := event.NewExporter(logfmt.NewHandler(io.Discard), &event.ExporterOptions{EnableNamespaces: true})
 	ctx := event.WithExporter(context.Background(), e)
@@ -28,6 +30,29 @@ func TestAllocs(t *testing.T) {
 	if allocs != 0 {
 		t.Errorf("Got %d allocs, expect 0", allocs)
 	}
+	allocs = int(testing.AllocsPerRun(5, func() {
+		event.Error(ctx, "message", anErr, anInt)
+	}))
+	if allocs != 0 {
+		t.Errorf("Got %d allocs for Error, expect 0", allocs)
+	}
+	e.SetPolicy("golang.org/x/exp/event_test", event.Policy{MinLevel: 1})
+	allocs = int(testing.AllocsPerRun(5, func() {
+		event.Log(ctx, "message", aString, anInt)
+	}))
+	if allocs != 0 {
+		t.Errorf("Got %d allocs with a namespace policy, expect 0", allocs)
+	}
+
+	e = event.NewExporter(logfmt.NewHandler(io.Discard), &event.ExporterOptions{DisableTracing: true})
+	ctx = event.WithExporter(context.Background(), e)
+	allocs = int(testing.AllocsPerRun(5, func() {
+		event.End(event.Start(ctx, "span", anInt))
+	}))
+	// the only allocation is the context that marks the span as dropped
+	if allocs > 1 {
+		t.Errorf("Got %d allocs with tracing disabled, expect at most 1", allocs)
+	}
 }
 
 func TestBenchAllocs(t *testing.T) {
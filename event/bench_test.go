@@ -16,6 +16,8 @@ import (
 )
 
 var (
+	benchGauge = event.NewFloatGauge("bench", nil)
+
 	eventLog = eventtest.Hooks{
 		AStart: func(ctx context.Context, a int) context.Context {
 			severity.Info.Log(ctx, eventtest.A.Msg, event.Int64(eventtest.A.Name, int64(a)))
@@ -61,14 +63,14 @@ var (
 
 	eventMetric = eventtest.Hooks{
 		AStart: func(ctx context.Context, a int) context.Context {
-			gauge.Record(ctx, 1, event.Int64("aValue", int64(a)))
-			gauge.Record(ctx, 1, event.Int64("aCount", 1))
+			benchGauge.Record(ctx, 1, event.Int64("aValue", int64(a)))
+			benchGauge.Record(ctx, 1, event.Int64("aCount", 1))
 			return ctx
 		},
 		AEnd: func(ctx context.Context) {},
 		BStart: func(ctx context.Context, b string) context.Context {
-			gauge.Record(ctx, 1, event.Int64("BLen", int64(len(b))))
-			gauge.Record(ctx, 1, event.Int64("B", 1))
+			benchGauge.Record(ctx, 1, event.Int64("BLen", int64(len(b))))
+			benchGauge.Record(ctx, 1, event.Int64("B", 1))
 			return ctx
 		},
 		BEnd: func(ctx context.Context) {},
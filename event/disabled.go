@@ -11,30 +11,70 @@ import (
 	"time"
 )
 
-type Builder struct{ ctx context.Context }
-type Exporter struct {
-	Now func() time.Time
+// This file provides a no-op implementation of the exported API of the
+// package, so that building with the disable_events tag removes the cost of
+// events without changes to the code that creates them.
+// It must be kept in step with the real API; TestDisabledAPI in internal/apicheck
+// checks that it is.
+
+// Event holds the information about an event that occurred.
+// It combines the event metadata with the user supplied labels.
+type Event struct {
+	ID     uint64
+	Parent uint64    // id of the parent event for this event
+	Source Source    // source of event; if empty, set by exporter to import path
+	At     time.Time // time at which the event is delivered to the exporter.
+	Kind   Kind
+	Labels []Label
 }
 
-func NewExporter(h interface{}) *Exporter { return &Exporter{} }
-
-func To(ctx context.Context) Builder                        { return Builder{} }
-func (b Builder) Clone() Builder                            { return b }
-func (b Builder) Label(label Label) Builder                 { return b }
-func (b Builder) Labels(labels ...Label) Builder            { return b }
-func (b Builder) Log(message string)                        {}
-func (b Builder) Logf(template string, args ...interface{}) {}
-func (b Builder) Metric()                                   {}
-func (b Builder) Annotate()                                 {}
-func (b Builder) End()                                      {}
-func (b Builder) Event() *Event                             { return &Event{} }
-func (b Builder) Start(name string) (context.Context, func()) {
-	return b.ctx, func() {}
+// Handler is a the type for something that handles events as they occur.
+type Handler interface {
+	// Event is called with each event.
+	Event(context.Context, *Event) context.Context
 }
 
-func newContext(ctx context.Context, exporter *Exporter, parent uint64) context.Context {
-	return ctx
+// Exporter synchronizes the delivery of events to handlers.
+type Exporter struct {
+	_ [0]func() // not comparable, like the real Exporter
 }
-func FromContext(ctx context.Context) *Target { return nil }
 
-func setDefaultExporter(e *Exporter) {}
+func NewExporter(handler Handler, opts *ExporterOptions) *Exporter { return &Exporter{} }
+
+func (e *Exporter) SetPolicy(namespace string, p Policy) {}
+func (e *Exporter) RemovePolicy(namespace string)        {}
+func (e *Exporter) Policy(namespace string) Policy       { return Policy{} }
+
+// A Callback reports the current values of observable metrics, such as the
+// length of a queue, by calling the methods of o.
+type Callback func(ctx context.Context, o *Observer)
+
+// An Observer delivers the values reported by a Callback as metric events.
+type Observer struct{}
+
+func (o *Observer) Int64(m Metric, v int64, labels ...Label)            {}
+func (o *Observer) Float64(m Metric, v float64, labels ...Label)        {}
+func (o *Observer) Duration(m Metric, v time.Duration, labels ...Label) {}
+
+func (e *Exporter) RegisterCallback(f Callback) (unregister func())   { return func() {} }
+func (e *Exporter) Collect(ctx context.Context)                       {}
+func (e *Exporter) CollectEvery(ctx context.Context, d time.Duration) { <-ctx.Done() }
+
+func WithExporter(ctx context.Context, e *Exporter) context.Context { return ctx }
+func SetDefaultExporter(e *Exporter)                                {}
+func RegisterHelper(v interface{})                                  {}
+
+func New(ctx context.Context, kind Kind) *Event { return nil }
+func (ev *Event) Clone() *Event                 { return ev }
+func (ev *Event) Trace()                        {}
+func (ev *Event) Deliver() context.Context      { return nil }
+func (ev *Event) Find(name string) Label        { return Label{} }
+
+func Log(ctx context.Context, msg string, labels ...Label)                    {}
+func Logf(ctx context.Context, msg string, args ...interface{})               {}
+func Error(ctx context.Context, msg string, err error, labels ...Label)       {}
+func Annotate(ctx context.Context, labels ...Label)                           {}
+func Start(ctx context.Context, name string, labels ...Label) context.Context { return ctx }
+func End(ctx context.Context, labels ...Label)                                {}
+
+func scanStack() Source { return Source{} }
@@ -0,0 +1,143 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ErrorStack is the key of the Stack label added to error events when
+	// ExporterOptions.EnableErrorStacks is set.
+	ErrorStack = interfaceKey("stack")
+	// ErrorCauses is the key of the Causes label added to error events when
+	// ExporterOptions.EnableErrorCauses is set.
+	ErrorCauses = interfaceKey("causes")
+)
+
+// maxStackDepth is the maximum number of frames captured for an error event.
+const maxStackDepth = 32
+
+// maxCauses bounds the walk of an error chain, in case of cycles.
+const maxCauses = 32
+
+// Stack is a call stack captured when an event was created.
+// The program counters are only turned into functions and lines when Frames
+// or String is called.
+type Stack struct {
+	pcs []uintptr
+}
+
+// captureStack returns the stack starting at its caller, after skipping skip
+// more frames.
+func captureStack(skip int) Stack {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	s := Stack{pcs: make([]uintptr, n)}
+	copy(s.pcs, pcs[:n])
+	return s
+}
+
+// PCs returns the program counters of the stack, innermost first.
+func (s Stack) PCs() []uintptr { return s.pcs }
+
+// Frames returns the symbolized frames of the stack, innermost first.
+func (s Stack) Frames() []runtime.Frame {
+	if len(s.pcs) == 0 {
+		return nil
+	}
+	var frames []runtime.Frame
+	iter := runtime.CallersFrames(s.pcs)
+	for {
+		f, more := iter.Next()
+		frames = append(frames, f)
+		if !more {
+			return frames
+		}
+	}
+}
+
+// String returns the stack with one "function file:line" entry per line.
+func (s Stack) String() string {
+	var b strings.Builder
+	for i, f := range s.Frames() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(f.Function)
+		b.WriteByte(' ')
+		b.WriteString(f.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(f.Line))
+	}
+	return b.String()
+}
+
+// Causes is the chain of errors found by unwrapping an error, starting with
+// the error itself.
+// Errors that wrap several errors contribute all of them, depth first.
+type Causes []error
+
+// ErrorChain unwraps err and returns the resulting chain.
+func ErrorChain(err error) Causes {
+	var c Causes
+	var walk func(err error)
+	walk = func(err error) {
+		for err != nil && len(c) < maxCauses {
+			c = append(c, err)
+			switch u := err.(type) {
+			case interface{ Unwrap() error }:
+				err = u.Unwrap()
+			case interface{ Unwrap() []error }:
+				for _, e := range u.Unwrap() {
+					walk(e)
+				}
+				return
+			default:
+				return
+			}
+		}
+	}
+	walk(err)
+	return c
+}
+
+// String returns the dynamic types of the errors in the chain, outermost
+// first, separated by commas.
+// The messages are not included, as the message of the outermost error
+// normally already contains them.
+func (c Causes) String() string {
+	var b strings.Builder
+	for i, err := range c {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%T", err)
+	}
+	return b.String()
+}
+
+// StackOf returns the stack captured for an error event, if any.
+func StackOf(ev *Event) (Stack, bool) {
+	v, ok := ErrorStack.Find(ev)
+	if !ok {
+		return Stack{}, false
+	}
+	s, ok := v.(Stack)
+	return s, ok
+}
+
+// CausesOf returns the error chain captured for an error event, if any.
+func CausesOf(ev *Event) (Causes, bool) {
+	v, ok := ErrorCauses.Find(ev)
+	if !ok {
+		return nil, false
+	}
+	c, ok := v.(Causes)
+	return c, ok
+}
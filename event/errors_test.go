@@ -0,0 +1,104 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package event_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/eventtest"
+)
+
+func TestErrorChain(t *testing.T) {
+	inner := &fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}
+	wrapped := fmt.Errorf("loading: %w", inner)
+	multi := &multiError{[]error{inner, err}}
+	for _, test := range []struct {
+		name   string
+		err    error
+		expect event.Causes
+		str    string
+	}{{
+		name:   "nil",
+		err:    nil,
+		expect: nil,
+		str:    "",
+	}, {
+		name:   "single",
+		err:    err,
+		expect: event.Causes{err},
+		str:    "*errors.errorString",
+	}, {
+		name:   "wrapped",
+		err:    wrapped,
+		expect: event.Causes{wrapped, inner, fs.ErrNotExist},
+		str:    "*fmt.wrapError,*fs.PathError,*errors.errorString",
+	}, {
+		name:   "multiple",
+		err:    multi,
+		expect: event.Causes{multi, inner, fs.ErrNotExist, err},
+		str:    "*event_test.multiError,*fs.PathError,*errors.errorString,*errors.errorString",
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			got := event.ErrorChain(test.err)
+			if diff := cmp.Diff(test.expect, got, cmp.Comparer(func(x, y error) bool { return x == y })); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+			if s := got.String(); s != test.str {
+				t.Errorf("got %q, want %q", s, test.str)
+			}
+		})
+	}
+}
+
+type multiError struct{ errs []error }
+
+func (m *multiError) Error() string   { return "multiple errors" }
+func (m *multiError) Unwrap() []error { return m.errs }
+
+func TestErrorDetails(t *testing.T) {
+	wrapped := fmt.Errorf("failed: %w", err)
+
+	h := &eventtest.CaptureHandler{}
+	ctx := event.WithExporter(context.Background(), event.NewExporter(h, eventtest.ExporterOptions()))
+	event.Error(ctx, "failed", wrapped)
+	if _, ok := event.StackOf(&h.Got[0]); ok {
+		t.Error("stack captured without EnableErrorStacks")
+	}
+	if _, ok := event.CausesOf(&h.Got[0]); ok {
+		t.Error("causes captured without EnableErrorCauses")
+	}
+
+	h.Reset()
+	opts := eventtest.ExporterOptions()
+	opts.EnableErrorStacks = true
+	opts.EnableErrorCauses = true
+	ctx = event.WithExporter(context.Background(), event.NewExporter(h, opts))
+	event.Error(ctx, "failed", wrapped)
+	ev := &h.Got[0]
+	stack, ok := event.StackOf(ev)
+	if !ok {
+		t.Fatal("no stack captured")
+	}
+	frames := stack.Frames()
+	if len(frames) == 0 || !strings.HasSuffix(frames[0].Function, ".TestErrorDetails") {
+		t.Errorf("stack does not start at the caller of Error:\n%s", stack)
+	}
+	causes, ok := event.CausesOf(ev)
+	if !ok {
+		t.Fatal("no causes captured")
+	}
+	if len(causes) != 2 || !errors.Is(causes[1], err) {
+		t.Errorf("got causes %v, want [%v %v]", causes, wrapped, err)
+	}
+}
@@ -2,6 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build !disable_events
+
 package event
 
 import (
@@ -20,9 +22,10 @@ type Event struct {
 	Kind   Kind
 	Labels []Label
 
-	ctx    context.Context
-	target *target
-	labels [preallocateLabels]Label
+	ctx      context.Context
+	target   *target
+	minLevel uint64 // from the Policy, checked on delivery
+	labels   [preallocateLabels]Label
 }
 
 // Handler is a the type for something that handles events as they occur.
@@ -58,8 +61,17 @@ func SetDefaultExporter(e *Exporter) {
 // it uses a pool of events.
 // Events are returned to the pool when Deliver is called. Failure to call
 // Deliver will exhaust the pool and cause allocations.
-// It returns nil if there is no active exporter for this kind of event.
+// It returns nil if there is no active exporter for this kind of event, or if
+// the exporter's Policy or Sampler dropped it.
 func New(ctx context.Context, kind Kind) *Event {
+	ev, _ := newEvent(ctx, kind)
+	return ev
+}
+
+// newEvent is like New, but if the event was dropped by the sampler, or is a
+// Start event dropped by a Policy, it also returns the target that made that
+// decision.
+func newEvent(ctx context.Context, kind Kind) (*Event, *target) {
 	var t *target
 	if v, ok := ctx.Value(contextKey).(*target); ok {
 		t = v
@@ -67,32 +79,47 @@ func New(ctx context.Context, kind Kind) *Event {
 		t = getDefaultTarget()
 	}
 	if t == nil {
-		return nil
+		return nil, nil
 	}
-	//TODO: we can change this to a much faster test
-	switch kind {
-	case LogKind:
-		if !t.exporter.loggingEnabled() {
-			return nil
+	var (
+		e        = t.exporter
+		source   Source
+		minLevel uint64
+	)
+	if kind != EndKind {
+		// End events follow the decision made for their Start event
+		ps := e.loadPolicies()
+		p := ps.def
+		if len(ps.spaces) > 0 {
+			source = e.sources.scanStack()
+			p = ps.lookup(source.Space)
 		}
-	case MetricKind:
-		if !t.exporter.metricsEnabled() {
-			return nil
+		if !p.enabled(kind) {
+			if kind == StartKind {
+				return nil, t
+			}
+			return nil, nil
 		}
-	case StartKind, EndKind:
-		if !t.exporter.tracingEnabled() {
-			return nil
+		if kind == LogKind {
+			minLevel = p.MinLevel
 		}
 	}
+	if !t.sample(ctx, kind) {
+		return nil, t
+	}
 	ev := eventPool.Get().(*Event)
 	*ev = Event{
-		ctx:    ctx,
-		target: t,
-		Kind:   kind,
-		Parent: t.parent,
+		ctx:      ctx,
+		target:   t,
+		Kind:     kind,
+		Parent:   t.parent,
+		minLevel: minLevel,
+	}
+	if e.opts.EnableNamespaces {
+		ev.Source = source
 	}
 	ev.Labels = ev.labels[:0]
-	return ev
+	return ev, nil
 }
 
 // Clone makes a deep copy of the Event.
@@ -113,6 +140,11 @@ func (ev *Event) Trace() {
 // This also returns the event to the pool, it is an error to do anything
 // with the event after it is delivered.
 func (ev *Event) Deliver() context.Context {
+	if ev.minLevel != 0 && ev.belowLevel(ev.minLevel) {
+		ctx := ev.ctx
+		eventPool.Put(ev)
+		return ctx
+	}
 	// get the event ready to send
 	ev.prepare()
 	ctx := ev.deliver()
@@ -60,6 +60,6 @@ func CmpOptions() []cmp.Option {
 		cmpopts.SortSlices(func(x, y event.Label) bool {
 			return x.Name < y.Name
 		}),
-		cmpopts.IgnoreFields(event.Event{}, "At", "ctx", "target", "labels"),
+		cmpopts.IgnoreFields(event.Event{}, "At", "ctx", "target", "minLevel", "labels"),
 	}
 }
@@ -0,0 +1,127 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package eventtest_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/eventtest"
+	"golang.org/x/exp/event/severity"
+)
+
+// recorder is a testing.TB that records errors instead of reporting them.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+var errDiskFull = errors.New("disk full")
+
+// instrumented is an example of instrumented code.
+func instrumented(ctx context.Context, n int) {
+	ctx = event.Start(ctx, "outer")
+	defer event.End(ctx)
+	for i := 0; i < n; i++ {
+		ctx := event.Start(ctx, "inner", event.Int64("i", int64(i)))
+		severity.Info.Log(ctx, "working", event.Int64("i", int64(i)))
+		event.End(ctx)
+	}
+	event.Error(ctx, "failed", errDiskFull)
+}
+
+func capture(n int) []event.Event {
+	ctx, h := eventtest.NewCapture()
+	instrumented(ctx, n)
+	return h.Got
+}
+
+func TestExpect(t *testing.T) {
+	events := capture(2)
+	ev := eventtest.Expect(t, events, eventtest.Kind(event.LogKind), eventtest.Label(event.Int64("i", 1)))
+	if ev == nil || ev.Find("msg").String() != "working" {
+		t.Errorf("Expect returned %v", ev)
+	}
+	eventtest.ExpectNone(t, events, eventtest.Msg("working"), eventtest.Label(event.Int64("i", 2)))
+	if got := len(eventtest.Find(events, eventtest.Kind(event.StartKind))); got != 3 {
+		t.Errorf("found %d start events, want 3", got)
+	}
+	isError := eventtest.LabelFunc("level", func(l event.Label) bool {
+		return severity.From(l) >= severity.Error
+	})
+	eventtest.ExpectNone(t, events, isError)
+	eventtest.Expect(t, events, eventtest.All(eventtest.Has("error"), eventtest.Msg("failed")))
+
+	r := &recorder{TB: t}
+	eventtest.Expect(r, events, eventtest.Msg("missing"))
+	eventtest.ExpectNone(r, events, eventtest.Msg("working"))
+	if len(r.errors) != 3 {
+		t.Errorf("got %d errors, want 3: %q", len(r.errors), r.errors)
+	}
+}
+
+func TestExpectTraces(t *testing.T) {
+	events := capture(2)
+	eventtest.ExpectTraces(t, events, eventtest.Span{
+		Name:     "outer",
+		Children: []eventtest.Span{{Name: "inner"}, {Name: "inner"}},
+	})
+
+	r := &recorder{TB: t}
+	eventtest.ExpectTraces(r, events, eventtest.Span{Name: "outer"})
+	// drop the final End
+	eventtest.ExpectTraces(r, events[:len(events)-1], eventtest.Span{
+		Name:     "outer",
+		Children: []eventtest.Span{{Name: "inner"}, {Name: "inner"}},
+	})
+	if len(r.errors) != 2 || !strings.Contains(r.errors[0], "mismatch") || !strings.Contains(r.errors[1], "not ended") {
+		t.Errorf("got errors %q, want a mismatch and a trace that was not ended", r.errors)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// the same events, from a different exporter, have different IDs
+	events := capture(1)
+	ctx, h := eventtest.NewCapture()
+	event.Log(ctx, "first")
+	instrumented(ctx, 1)
+	if diff := cmp.Diff(eventtest.Normalize(events), eventtest.Normalize(h.Got[1:]), eventtest.CmpOptions()...); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	for _, ev := range eventtest.Normalize(events) {
+		if !ev.At.IsZero() {
+			t.Errorf("event %d has time %v", ev.ID, ev.At)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGolden(t *testing.T) {
+	eventtest.Golden(t, "testdata/trace.golden", capture(2), *update)
+
+	if *update {
+		return
+	}
+	r := &recorder{TB: t}
+	eventtest.Golden(r, "testdata/trace.golden", capture(1), false)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "update the golden file") {
+		t.Errorf("got errors %q, want a mismatch", r.errors)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eventtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+)
+
+// Golden compares the normalized events, printed in logfmt format, with the
+// contents of the golden file at path, and reports an error if they differ.
+// If update is set, it writes the file instead. Test packages usually set it
+// from a flag of their own, such as -update.
+func Golden(tb testing.TB, path string, events []event.Event, update bool) {
+	tb.Helper()
+	got := Format(Normalize(events)) + "\n"
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o666); err != nil {
+			tb.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("%v (update the golden file to create it)", err)
+	}
+	if diff := cmp.Diff(strings.Split(string(want), "\n"), strings.Split(got, "\n")); diff != "" {
+		tb.Errorf("events differ from %s (-want, +got):\n%s\nupdate the golden file to accept the new events", path, diff)
+	}
+}
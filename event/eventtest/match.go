@@ -0,0 +1,133 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eventtest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/adapter/logfmt"
+)
+
+// A Predicate reports whether an event has some property.
+type Predicate func(*event.Event) bool
+
+// Kind matches events of kind k.
+func Kind(k event.Kind) Predicate {
+	return func(ev *event.Event) bool { return ev.Kind == k }
+}
+
+// Has matches events with a label called name.
+func Has(name string) Predicate {
+	return func(ev *event.Event) bool { return hasLabel(ev, name) }
+}
+
+// Label matches events with a label equal to l.
+func Label(l event.Label) Predicate {
+	return LabelFunc(l.Name, l.Equal)
+}
+
+// LabelFunc matches events with a label called name for which f returns true.
+func LabelFunc(name string, f func(event.Label) bool) Predicate {
+	return func(ev *event.Event) bool {
+		for _, l := range ev.Labels {
+			if l.Name == name && f(l) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Msg matches events with the message msg.
+func Msg(msg string) Predicate {
+	return Label(event.String("msg", msg))
+}
+
+// All matches events that match all of ps.
+func All(ps ...Predicate) Predicate {
+	return func(ev *event.Event) bool { return matches(ev, ps) }
+}
+
+func hasLabel(ev *event.Event, name string) bool {
+	for _, l := range ev.Labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(ev *event.Event, ps []Predicate) bool {
+	for _, p := range ps {
+		if !p(ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the events that match all of ps.
+func Find(events []event.Event, ps ...Predicate) []event.Event {
+	var found []event.Event
+	for i := range events {
+		if matches(&events[i], ps) {
+			found = append(found, events[i])
+		}
+	}
+	return found
+}
+
+// Expect reports an error if none of events match all of ps, and otherwise
+// returns the first event that does.
+func Expect(tb testing.TB, events []event.Event, ps ...Predicate) *event.Event {
+	tb.Helper()
+	for i := range events {
+		if matches(&events[i], ps) {
+			return &events[i]
+		}
+	}
+	tb.Errorf("no matching event in:\n%s", Format(events))
+	return nil
+}
+
+// ExpectNone reports an error for each of events that match all of ps.
+func ExpectNone(tb testing.TB, events []event.Event, ps ...Predicate) {
+	tb.Helper()
+	for _, ev := range Find(events, ps...) {
+		tb.Errorf("unexpected event: %s", Format([]event.Event{ev}))
+	}
+}
+
+// Format returns events printed in logfmt format, one per line.
+func Format(events []event.Event) string {
+	var b strings.Builder
+	var p logfmt.Printer
+	for i := range events {
+		p.Event(&b, &events[i])
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Normalize returns a copy of events with the IDs renumbered from 1 in the
+// order the events occur, and the times removed, so that the events can be
+// compared with those of another run.
+// Parents that are not among the events become 0.
+func Normalize(events []event.Event) []event.Event {
+	ids := map[uint64]uint64{}
+	result := make([]event.Event, len(events))
+	for i, ev := range events {
+		if ev.ID != 0 {
+			ids[ev.ID] = uint64(len(ids) + 1)
+		}
+		ev.ID = ids[ev.ID]
+		ev.Parent = ids[ev.Parent]
+		ev.At = time.Time{}
+		result[i] = ev
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package eventtest
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/exp/event"
+)
+
+// A Span is a node in the tree of traces built from a list of events.
+type Span struct {
+	Name     string
+	Children []Span
+}
+
+// Traces returns the traces started by events, as a tree following the
+// Parent of each Start event.
+// Spans whose parent is not among the events are roots.
+// Spans are in the order they were started.
+func Traces(events []event.Event) []Span {
+	type node struct {
+		name     string
+		children []uint64
+	}
+	nodes := map[uint64]*node{}
+	var order []uint64
+	for _, ev := range events {
+		if ev.Kind == event.StartKind {
+			nodes[ev.ID] = &node{name: ev.Find("name").String()}
+			order = append(order, ev.ID)
+		}
+	}
+	var roots []uint64
+	for _, ev := range events {
+		if ev.Kind != event.StartKind {
+			continue
+		}
+		if parent, ok := nodes[ev.Parent]; ok {
+			parent.children = append(parent.children, ev.ID)
+		} else {
+			roots = append(roots, ev.ID)
+		}
+	}
+	var build func(ids []uint64) []Span
+	build = func(ids []uint64) []Span {
+		var spans []Span
+		for _, id := range ids {
+			n := nodes[id]
+			spans = append(spans, Span{Name: n.name, Children: build(n.children)})
+		}
+		return spans
+	}
+	return build(roots)
+}
+
+// ExpectTraces reports an error if the traces started by events do not have
+// the shape of want, or if any of them were not ended.
+func ExpectTraces(tb testing.TB, events []event.Event, want ...Span) {
+	tb.Helper()
+	if diff := cmp.Diff(want, Traces(events), cmpopts.EquateEmpty()); diff != "" {
+		tb.Errorf("traces mismatch (-want, +got):\n%s", diff)
+	}
+	open := map[uint64]bool{}
+	for _, ev := range events {
+		switch ev.Kind {
+		case event.StartKind:
+			open[ev.ID] = true
+		case event.EndKind:
+			if !open[ev.Parent] {
+				tb.Errorf("end of trace %d that was not started: %s", ev.Parent, Format([]event.Event{ev}))
+			}
+			delete(open, ev.Parent)
+		}
+	}
+	for _, ev := range events {
+		if ev.Kind == event.StartKind && open[ev.ID] {
+			tb.Errorf("trace was not ended: %s", Format([]event.Event{ev}))
+		}
+	}
+}
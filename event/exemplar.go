@@ -0,0 +1,104 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// An Exemplar is a single recorded metric value together with the trace
+// that was active when it was recorded.
+type Exemplar struct {
+	// Trace is the ID of the Start event of the enclosing trace.
+	Trace uint64
+	At    time.Time
+	Value Label
+	// Labels holds any extra information a handler wants to keep with the
+	// exemplar, such as the trace identifiers of another tracing system.
+	Labels []Label
+}
+
+// ExemplarOf returns the exemplar for a metric event.
+// Metric events are linked to the trace active in the context passed to
+// Record through their Parent; ExemplarOf reports false if there was none.
+func ExemplarOf(ev *Event) (Exemplar, bool) {
+	if ev.Kind != MetricKind || ev.Parent == 0 {
+		return Exemplar{}, false
+	}
+	v := ev.Find(MetricVal)
+	if !v.HasValue() {
+		return Exemplar{}, false
+	}
+	return Exemplar{Trace: ev.Parent, At: ev.At, Value: v}, true
+}
+
+// ExemplarReservoir retains the most recent exemplars for each bucket of each
+// metric, up to a fixed number per bucket.
+// It is intended for use by handlers that aggregate metric events.
+// It is safe for concurrent use.
+type ExemplarReservoir struct {
+	size int
+
+	mu      sync.Mutex
+	buckets map[Metric]map[int]*exemplarRing
+}
+
+// exemplarRing holds the most recent exemplars of a bucket.
+type exemplarRing struct {
+	entries []Exemplar
+	next    int // index of the oldest entry once entries is full
+}
+
+// NewExemplarReservoir returns a reservoir that keeps up to size exemplars
+// per bucket.
+func NewExemplarReservoir(size int) *ExemplarReservoir {
+	if size < 1 {
+		size = 1
+	}
+	return &ExemplarReservoir{size: size, buckets: map[Metric]map[int]*exemplarRing{}}
+}
+
+// Offer records ex in the given bucket of m, discarding the oldest exemplar of
+// that bucket if it is full.
+func (r *ExemplarReservoir) Offer(m Metric, bucket int, ex Exemplar) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bs, ok := r.buckets[m]
+	if !ok {
+		bs = map[int]*exemplarRing{}
+		r.buckets[m] = bs
+	}
+	ring, ok := bs[bucket]
+	if !ok {
+		ring = &exemplarRing{entries: make([]Exemplar, 0, r.size)}
+		bs[bucket] = ring
+	}
+	if len(ring.entries) < r.size {
+		ring.entries = append(ring.entries, ex)
+		return
+	}
+	ring.entries[ring.next] = ex
+	ring.next = (ring.next + 1) % r.size
+}
+
+// Exemplars returns the exemplars retained for m, indexed by bucket.
+// Each bucket's exemplars are ordered oldest first.
+func (r *ExemplarReservoir) Exemplars(m Metric) map[int][]Exemplar {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bs := r.buckets[m]
+	if len(bs) == 0 {
+		return nil
+	}
+	result := make(map[int][]Exemplar, len(bs))
+	for b, ring := range bs {
+		exs := make([]Exemplar, 0, len(ring.entries))
+		exs = append(exs, ring.entries[ring.next:]...)
+		exs = append(exs, ring.entries[:ring.next]...)
+		result[b] = exs
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package event_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/eventtest"
+)
+
+func TestExemplarOf(t *testing.T) {
+	ctx, h := eventtest.NewCapture()
+	latency.Record(ctx, time.Second)
+	sctx := event.Start(ctx, "span")
+	latency.Record(sctx, 2*time.Second)
+	event.End(sctx)
+
+	// events: metric, start, metric, end
+	if _, ok := event.ExemplarOf(&h.Got[0]); ok {
+		t.Error("got exemplar for metric recorded outside a trace")
+	}
+	if _, ok := event.ExemplarOf(&h.Got[1]); ok {
+		t.Error("got exemplar for start event")
+	}
+	ex, ok := event.ExemplarOf(&h.Got[2])
+	if !ok {
+		t.Fatal("no exemplar for metric recorded inside a trace")
+	}
+	if ex.Trace != h.Got[1].ID {
+		t.Errorf("got trace %d, want %d", ex.Trace, h.Got[1].ID)
+	}
+	if got := ex.Value.Duration(); got != 2*time.Second {
+		t.Errorf("got value %v, want 2s", got)
+	}
+}
+
+func TestExemplarReservoir(t *testing.T) {
+	r := event.NewExemplarReservoir(2)
+	for i := 1; i <= 5; i++ {
+		r.Offer(latency, i%2, event.Exemplar{Trace: uint64(i)})
+	}
+	r.Offer(counter, 0, event.Exemplar{Trace: 9})
+	traces := func(m event.Metric) map[int][]uint64 {
+		got := map[int][]uint64{}
+		for b, exs := range r.Exemplars(m) {
+			for _, ex := range exs {
+				got[b] = append(got[b], ex.Trace)
+			}
+		}
+		return got
+	}
+	want := map[int][]uint64{0: {2, 4}, 1: {3, 5}}
+	if diff := cmp.Diff(want, traces(latency)); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	want = map[int][]uint64{0: {9}}
+	if diff := cmp.Diff(want, traces(counter)); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+	if got := r.Exemplars(gauge); got != nil {
+		t.Errorf("got %v for unrecorded metric, want nil", got)
+	}
+}
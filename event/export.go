@@ -21,7 +21,14 @@ type Exporter struct {
 
 	mu      sync.Mutex
 	handler Handler
-	sources sources
+
+	sources *callerCache
+
+	policyMu sync.Mutex     // serializes changes to policies
+	policies unsafe.Pointer // *policies, accessed using atomic
+
+	callbackMu sync.Mutex // guards callbacks, which are called outside mu
+	callbacks  []*callbackEntry
 }
 
 // target is a bound exporter.
@@ -29,22 +36,9 @@ type Exporter struct {
 type target struct {
 	exporter  *Exporter
 	parent    uint64
-	startTime time.Time // for trace latency
-}
-
-type ExporterOptions struct {
-	// If non-nil, sets zero Event.At on delivery.
-	Now func() time.Time
-
-	// Disable some event types, for better performance.
-	DisableLogging     bool
-	DisableTracing     bool
-	DisableAnnotations bool
-	DisableMetrics     bool
-
-	// Enable automatically setting the event Namespace to the calling package's
-	// import path.
-	EnableNamespaces bool
+	startTime time.Time      // for trace latency
+	unsampled bool           // the enclosing trace was dropped by the sampler or a policy
+	dropped   unsafe.Pointer // *target used by unsampledContext, made on first use
 }
 
 // contextKeyType is used as the key for storing a contextValue on the context.
@@ -64,7 +58,7 @@ func NewExporter(handler Handler, opts *ExporterOptions) *Exporter {
 	}
 	e := &Exporter{
 		handler: handler,
-		sources: newCallers(),
+		sources: newCallerCache(),
 	}
 	if opts != nil {
 		e.opts = *opts
@@ -72,9 +66,45 @@ func NewExporter(handler Handler, opts *ExporterOptions) *Exporter {
 	if e.opts.Now == nil {
 		e.opts.Now = time.Now
 	}
+	if e.opts.After == nil {
+		e.opts.After = time.After
+	}
+	e.policies = unsafe.Pointer(&policies{def: Policy{
+		DisableLogging:     e.opts.DisableLogging,
+		DisableTracing:     e.opts.DisableTracing,
+		DisableAnnotations: e.opts.DisableAnnotations,
+		DisableMetrics:     e.opts.DisableMetrics,
+	}})
 	return e
 }
 
+// unsampledContext returns a context that records that a trace was dropped by
+// the sampler, so that the events inside it and its End can follow that
+// decision.
+func (t *target) unsampledContext(ctx context.Context) context.Context {
+	d := (*target)(atomic.LoadPointer(&t.dropped))
+	if d == nil {
+		d = &target{exporter: t.exporter, parent: t.parent, unsampled: true}
+		atomic.StorePointer(&t.dropped, unsafe.Pointer(d))
+	}
+	return context.WithValue(ctx, contextKey, d)
+}
+
+// sample reports whether an event of the given kind should be created.
+func (t *target) sample(ctx context.Context, kind Kind) bool {
+	switch kind {
+	case EndKind:
+		return !t.unsampled
+	case MetricKind:
+		return true
+	}
+	s := t.exporter.opts.Sampler
+	if s == nil {
+		return true
+	}
+	return s.Sample(ctx, SampleInfo{Kind: kind, Parent: t.parent, Unsampled: t.unsampled})
+}
+
 func setDefaultExporter(e *Exporter) {
 	atomic.StorePointer(&defaultTarget, unsafe.Pointer(&target{exporter: e}))
 }
@@ -110,7 +140,5 @@ func (ev *Event) prepare() {
 	}
 }
 
-func (e *Exporter) loggingEnabled() bool     { return !e.opts.DisableLogging }
-func (e *Exporter) annotationsEnabled() bool { return !e.opts.DisableAnnotations }
-func (e *Exporter) tracingEnabled() bool     { return !e.opts.DisableTracing }
-func (e *Exporter) metricsEnabled() bool     { return !e.opts.DisableMetrics }
+func (e *Exporter) errorStacksEnabled() bool { return e.opts.EnableErrorStacks }
+func (e *Exporter) errorCausesEnabled() bool { return e.opts.EnableErrorCauses }
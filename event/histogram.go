@@ -0,0 +1,124 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Aggregation is a hint to handlers about how the values of a metric should
+// be aggregated.
+type Aggregation int
+
+const (
+	// AggregateDefault leaves the choice to the handler, which normally sums
+	// counters, keeps the last value of gauges and builds histograms of
+	// distributions.
+	AggregateDefault Aggregation = iota
+	// AggregateSum adds up the recorded values.
+	AggregateSum
+	// AggregateLastValue keeps only the most recent value.
+	AggregateLastValue
+	// AggregateHistogram counts the recorded values in buckets.
+	AggregateHistogram
+)
+
+func (a Aggregation) String() string {
+	switch a {
+	case AggregateDefault:
+		return "default"
+	case AggregateSum:
+		return "sum"
+	case AggregateLastValue:
+		return "lastvalue"
+	case AggregateHistogram:
+		return "histogram"
+	default:
+		return fmt.Sprintf("Aggregation(%d)", int(a))
+	}
+}
+
+// Buckets are the upper bounds of the buckets of a histogram.
+// A value v falls in the first bucket whose bound is at least v, or in an
+// extra overflow bucket if v is larger than all of them.
+//
+// Bounds are in the units the metric records: int64 values for an
+// IntDistribution and nanoseconds for a DurationDistribution, which is what
+// DurationBuckets provides.
+type Buckets struct {
+	bounds []float64
+}
+
+// ExplicitBuckets returns buckets with the given upper bounds.
+// It panics if the bounds are not in increasing order.
+func ExplicitBuckets(bounds ...float64) *Buckets {
+	for i, b := range bounds {
+		if math.IsNaN(b) || (i > 0 && b <= bounds[i-1]) {
+			panic(fmt.Sprintf("event: bucket bounds not increasing: %v", bounds))
+		}
+	}
+	return &Buckets{bounds: append([]float64(nil), bounds...)}
+}
+
+// DurationBuckets returns buckets with the given durations as upper bounds.
+// It panics if the durations are not in increasing order.
+func DurationBuckets(bounds ...time.Duration) *Buckets {
+	fs := make([]float64, len(bounds))
+	for i, d := range bounds {
+		fs[i] = float64(d)
+	}
+	return ExplicitBuckets(fs...)
+}
+
+// LinearBuckets returns count buckets, the first with upper bound start and
+// each following one width wider.
+// It panics if count is less than 1 or width is not positive.
+func LinearBuckets(start, width float64, count int) *Buckets {
+	if count < 1 || !(width > 0) {
+		panic(fmt.Sprintf("event: invalid linear buckets: width %v, count %d", width, count))
+	}
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start + float64(i)*width
+	}
+	return &Buckets{bounds: bounds}
+}
+
+// ExponentialBuckets returns count buckets, the first with upper bound start
+// and each following one factor times larger.
+// It panics if count is less than 1, start is not positive or factor is not
+// greater than 1.
+func ExponentialBuckets(start, factor float64, count int) *Buckets {
+	if count < 1 || !(start > 0) || !(factor > 1) {
+		panic(fmt.Sprintf("event: invalid exponential buckets: start %v, factor %v, count %d", start, factor, count))
+	}
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start
+		start *= factor
+	}
+	return &Buckets{bounds: bounds}
+}
+
+// Bounds returns a copy of the upper bounds of b, in increasing order.
+// It returns nil for a nil *Buckets.
+func (b *Buckets) Bounds() []float64 {
+	if b == nil {
+		return nil
+	}
+	return append([]float64(nil), b.bounds...)
+}
+
+// Index returns the index of the bucket v falls in, which is len(b.Bounds())
+// for the overflow bucket.
+func (b *Buckets) Index(v float64) int {
+	if b == nil {
+		return 0
+	}
+	return sort.SearchFloat64s(b.bounds, v)
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package event_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+)
+
+func TestBuckets(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		buckets *event.Buckets
+		want    []float64
+	}{
+		{"explicit", event.ExplicitBuckets(1, 5, 10), []float64{1, 5, 10}},
+		{"linear", event.LinearBuckets(10, 5, 4), []float64{10, 15, 20, 25}},
+		{"exponential", event.ExponentialBuckets(1, 2, 5), []float64{1, 2, 4, 8, 16}},
+		{"durations", event.DurationBuckets(time.Microsecond, time.Millisecond), []float64{1e3, 1e6}},
+		{"nil", nil, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.buckets.Bounds()
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+			if got != nil {
+				// Bounds returns a copy
+				got[0] = -1
+				if test.buckets.Bounds()[0] == -1 {
+					t.Error("modifying the result of Bounds changed the buckets")
+				}
+			}
+		})
+	}
+}
+
+func TestBucketsIndex(t *testing.T) {
+	b := event.ExplicitBuckets(1, 5, 10)
+	for _, test := range []struct {
+		v    float64
+		want int
+	}{
+		{-3, 0}, {1, 0}, {1.5, 1}, {5, 1}, {7, 2}, {10, 2}, {11, 3},
+	} {
+		if got := b.Index(test.v); got != test.want {
+			t.Errorf("Index(%v) = %d, want %d", test.v, got, test.want)
+		}
+	}
+}
+
+func TestBucketsPanic(t *testing.T) {
+	for name, f := range map[string]func(){
+		"explicit unordered":     func() { event.ExplicitBuckets(1, 3, 2) },
+		"explicit duplicate":     func() { event.ExplicitBuckets(1, 1) },
+		"durations unordered":    func() { event.DurationBuckets(time.Second, time.Millisecond) },
+		"linear zero width":      func() { event.LinearBuckets(0, 0, 3) },
+		"linear no buckets":      func() { event.LinearBuckets(0, 1, 0) },
+		"exponential zero start": func() { event.ExponentialBuckets(0, 2, 3) },
+		"exponential factor 1":   func() { event.ExponentialBuckets(1, 1, 3) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			f()
+		})
+	}
+}
+
+func TestMetricOptions(t *testing.T) {
+	buckets := event.DurationBuckets(time.Millisecond, time.Second)
+	for _, test := range []struct {
+		metric event.Metric
+		want   event.MetricOptions
+	}{
+		{
+			event.NewDuration("latency", nil),
+			event.MetricOptions{Unit: event.UnitDimensionless},
+		},
+		{
+			event.NewDuration("latency", &event.MetricOptions{Unit: event.UnitSeconds, Buckets: buckets}),
+			event.MetricOptions{Unit: event.UnitSeconds, Buckets: buckets},
+		},
+		{
+			event.NewIntDistribution("size", &event.MetricOptions{Unit: event.UnitBytes, Aggregation: event.AggregateSum}),
+			event.MetricOptions{Unit: event.UnitBytes, Aggregation: event.AggregateSum},
+		},
+		{
+			event.NewCounter("hits", nil),
+			event.MetricOptions{Unit: event.UnitDimensionless},
+		},
+	} {
+		got := test.metric.Options()
+		test.want.Namespace = "golang.org/x/exp/event_test"
+		if got != test.want {
+			t.Errorf("%s: got options %+v, want %+v", test.metric.Name(), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apicheck_test
+
+import (
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/exp/apidiff"
+)
+
+// TestDisabledAPI checks that building the event package with the
+// disable_events tag does not change its exported API.
+func TestDisabledAPI(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type checks the standard library from source")
+	}
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+	enabled := loadPackage(t, fset, imp)
+	disabled := loadPackage(t, fset, imp, "disable_events")
+	for _, c := range apidiff.Changes(enabled, disabled).Changes {
+		t.Errorf("disable_events: %s", c.Message)
+	}
+	// Anything only in the disabled build shows up as an incompatible
+	// change in this direction.
+	for _, c := range apidiff.Changes(disabled, enabled).Changes {
+		if !c.Compatible {
+			t.Errorf("disable_events: %s", c.Message)
+		}
+	}
+}
+
+// loadPackage type checks the event package as it would be built with the
+// given build tags.
+func loadPackage(t *testing.T, fset *token.FileSet, imp types.Importer, tags ...string) *types.Package {
+	t.Helper()
+	ctx := build.Default
+	ctx.BuildTags = tags
+	bp, err := ctx.ImportDir("../..", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(bp.Dir, name), nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: imp}
+	pkg, err := conf.Check("golang.org/x/exp/event", fset, files, nil)
+	if err != nil {
+		t.Fatalf("tags %v: %v", tags, err)
+	}
+	return pkg
+}
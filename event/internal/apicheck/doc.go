@@ -0,0 +1,9 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package apicheck holds the checks of the event package that need
+// golang.org/x/exp/apidiff.
+// It is a module of its own, so that the event module does not depend on the
+// rest of golang.org/x/exp. Run the checks with go test in this directory.
+package apicheck
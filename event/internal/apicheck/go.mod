@@ -0,0 +1,9 @@
+module golang.org/x/exp/event/internal/apicheck
+
+go 1.20
+
+require golang.org/x/exp v0.0.0-00010101000000-000000000000
+
+require golang.org/x/tools v0.16.0 // indirect
+
+replace golang.org/x/exp => ../../..
@@ -0,0 +1,99 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	MetricKey      = interfaceKey("metric")
+	MetricVal      = "metricValue"
+	DurationMetric = interfaceKey("durationMetric")
+)
+
+type Kind int
+
+const (
+	unknownKind = Kind(iota)
+
+	LogKind
+	MetricKind
+	StartKind
+	EndKind
+
+	dynamicKindStart
+)
+
+type (
+	valueKey     string
+	interfaceKey string
+)
+
+var (
+	dynamicKindMu    sync.Mutex
+	nextDynamicKind  = dynamicKindStart
+	dynamicKindNames map[Kind]string
+)
+
+func NewKind(name string) Kind {
+	dynamicKindMu.Lock()
+	defer dynamicKindMu.Unlock()
+	for _, n := range dynamicKindNames {
+		if n == name {
+			panic(fmt.Errorf("kind %s is already registered", name))
+		}
+	}
+	k := nextDynamicKind
+	nextDynamicKind++
+	dynamicKindNames[k] = name
+	return k
+}
+
+func (k Kind) String() string {
+	switch k {
+	case unknownKind:
+		return "unknown"
+	case LogKind:
+		return "log"
+	case MetricKind:
+		return "metric"
+	case StartKind:
+		return "start"
+	case EndKind:
+		return "end"
+	default:
+		dynamicKindMu.Lock()
+		defer dynamicKindMu.Unlock()
+		name, ok := dynamicKindNames[k]
+		if !ok {
+			return fmt.Sprintf("?unknownKind:%d?", k)
+		}
+		return name
+	}
+}
+
+func (k interfaceKey) Of(v interface{}) Label {
+	return Value(string(k), v)
+}
+
+func (k interfaceKey) Find(ev *Event) (interface{}, bool) {
+	v, ok := lookupValue(string(k), ev.Labels)
+	if !ok {
+		return nil, false
+	}
+	return v.Interface(), true
+
+}
+
+func lookupValue(name string, labels []Label) (Label, bool) {
+	for i := len(labels) - 1; i >= 0; i-- {
+		if labels[i].Name == name {
+			return labels[i], true
+		}
+	}
+	return Label{}, false
+}
@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 )
@@ -27,6 +29,9 @@ type stringptr unsafe.Pointer
 // bytesptr is used in untyped when the Value is a byte slice
 type bytesptr unsafe.Pointer
 
+// groupptr is used in untyped when the Value is a group of labels
+type groupptr unsafe.Pointer
+
 // int64Kind is used in untyped when the Value is a signed integer
 type int64Kind struct{}
 
@@ -69,6 +74,17 @@ func (l Label) Equal(l2 Label) bool {
 		return l2.IsBool() && l.packed == l2.packed
 	case l.IsDuration():
 		return l2.IsDuration() && l.packed == l2.packed
+	case l.IsGroup():
+		if !l2.IsGroup() || l.packed != l2.packed {
+			return false
+		}
+		g1, g2 := l.Group(), l2.Group()
+		for i := range g1 {
+			if !g1[i].Equal(g2[i]) {
+				return false
+			}
+		}
+		return true
 	default:
 		return l.untyped == l2.untyped
 	}
@@ -96,6 +112,8 @@ func (v Label) Interface() interface{} {
 		return v.Bool()
 	case v.IsDuration():
 		return v.Duration()
+	case v.IsGroup():
+		return v.Group()
 	default:
 		return v.untyped
 	}
@@ -132,6 +150,21 @@ func (v Label) String() string {
 		} else {
 			return "false"
 		}
+	case v.IsGroup():
+		var b strings.Builder
+		b.WriteByte('{')
+		for i, l := range v.Group() {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(l.Name)
+			if l.HasValue() {
+				b.WriteByte('=')
+				b.WriteString(l.String())
+			}
+		}
+		b.WriteByte('}')
+		return b.String()
 	default:
 		return fmt.Sprint(v.Interface())
 	}
@@ -270,3 +303,53 @@ func (v Label) IsDuration() bool {
 	_, ok := v.untyped.(durationKind)
 	return ok
 }
+
+// Group returns a new Value holding a group of labels, for structured values
+// such as the fields of an HTTP request.
+// The labels slice is retained by the Label, it must not be modified
+// afterwards.
+func Group(name string, labels ...Label) Label {
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&labels))
+	return Label{Name: name, packed: uint64(hdr.Len), untyped: groupptr(hdr.Data)}
+}
+
+// Group returns the labels of a group value.
+// It will panic for any value for which IsGroup is not true.
+func (v Label) Group() []Label {
+	gp, ok := v.untyped.(groupptr)
+	if !ok {
+		panic("Group called on non-group value")
+	}
+	var labels []Label
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&labels))
+	hdr.Data = uintptr(gp)
+	hdr.Len = int(v.packed)
+	hdr.Cap = hdr.Len
+	return labels
+}
+
+// IsGroup returns true if the value was built with Group.
+func (v Label) IsGroup() bool {
+	_, ok := v.untyped.(groupptr)
+	return ok
+}
+
+// Map returns a group label holding one label per entry of m, in key order.
+// Values that are themselves of type map[string]interface{} become nested
+// groups.
+func Map(name string, m map[string]interface{}) Label {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	labels := make([]Label, len(keys))
+	for i, k := range keys {
+		if sub, ok := m[k].(map[string]interface{}); ok {
+			labels[i] = Map(k, sub)
+		} else {
+			labels[i] = Value(k, m[k])
+		}
+	}
+	return Group(name, labels...)
+}
@@ -44,6 +44,11 @@ func TestOfAs(t *testing.T) {
 	if got := v.Interface(); got != tm {
 		t.Errorf("got %v, want %v", got, tm)
 	}
+	g := []event.Label{event.Int64("a", 1), event.String("b", "c")}
+	v = event.Group("key", g...)
+	if got := v.Group(); len(got) != len(g) || &got[0] != &g[0] {
+		t.Errorf("got %v, want %v", got, g)
+	}
 	var vnil event.Label
 	if got := vnil.Interface(); got != nil {
 		t.Errorf("got %v, want nil", got)
@@ -64,6 +69,10 @@ func TestEqual(t *testing.T) {
 		event.Bool("key", false),
 		event.Value("key", &x),
 		event.Value("key", &y),
+		event.Group("key"),
+		event.Group("key", event.Int64("a", 1)),
+		event.Group("key", event.Int64("a", 2)),
+		event.Group("key", event.Int64("a", 1), event.Int64("b", 1)),
 	}
 	for i, v1 := range vals {
 		for j, v2 := range vals {
@@ -97,6 +106,7 @@ func TestPanics(t *testing.T) {
 		{"bool", func() { event.Int64("key", 3).Bool() }},
 		{"duration", func() { event.Value("key", "value").Duration() }},
 		{"bytes", func() { event.String("key", "value").Bytes() }},
+		{"group", func() { event.String("key", "value").Group() }},
 	} {
 		if !panics(test.f) {
 			t.Errorf("%s: got no panic, want panic", test.name)
@@ -115,6 +125,7 @@ func TestString(t *testing.T) {
 		{event.Bool("key", true), "true"},
 		{event.String("key", "foo"), "foo"},
 		{event.Value("key", time.Duration(3*time.Second)), "3s"},
+		{event.Group("key", event.String("a", "x"), event.Group("b", event.Int64("c", 1))), "{a=x b={c=1}}"},
 	} {
 		if got := test.v.String(); got != test.want {
 			t.Errorf("%#v: got %q, want %q", test.v, got, test.want)
@@ -150,3 +161,17 @@ func TestNoAlloc(t *testing.T) {
 	_ = s
 	_ = x
 }
+
+func TestMap(t *testing.T) {
+	got := event.Map("http", map[string]interface{}{
+		"status":  200,
+		"request": map[string]interface{}{"method": "GET", "path": "/"},
+	})
+	want := event.Group("http",
+		event.Group("request", event.Value("method", "GET"), event.Value("path", "/")),
+		event.Value("status", 200),
+	)
+	if !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -16,9 +16,15 @@ const (
 	UnitDimensionless Unit = "1"
 	UnitBytes         Unit = "By"
 	UnitMilliseconds  Unit = "ms"
+	UnitNanoseconds   Unit = "ns"
+	UnitMicroseconds  Unit = "us"
+	UnitSeconds       Unit = "s"
 )
 
 // A Metric represents a kind of recorded measurement.
+// Metric events recorded inside a trace have the trace's Start event as their
+// Parent, which links each value to the trace that produced it; see
+// ExemplarOf.
 type Metric interface {
 	Name() string
 	Options() MetricOptions
@@ -34,10 +40,20 @@ type MetricOptions struct {
 	Description string
 
 	// Optional unit for the metric. Defaults to UnitDimensionless.
+	// A DurationDistribution may use one of the time units, which handlers
+	// report its values in; with any other unit they report nanoseconds.
 	Unit Unit
+
+	// Optional hint about how handlers should aggregate the metric.
+	Aggregation Aggregation
+
+	// Optional buckets for handlers that build histograms of a distribution.
+	// If nil, handlers choose their own.
+	Buckets *Buckets
 }
 
 // A Counter is a metric that counts something cumulatively.
+// Each value recorded is an increment, which handlers add to the count.
 type Counter struct {
 	name string
 	opts MetricOptions
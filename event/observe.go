@@ -0,0 +1,149 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package event
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A Callback reports the current values of observable metrics, such as the
+// length of a queue, by calling the methods of o.
+// Callbacks are registered with Exporter.RegisterCallback, and called each
+// time the exporter collects metrics.
+type Callback func(ctx context.Context, o *Observer)
+
+// An Observer delivers the values reported by a Callback as metric events.
+// All the events of one collection have the same time.
+//
+// Each value must have the type that its metric records: int64 for a Counter
+// or IntDistribution, float64 for a FloatGauge, and time.Duration for a
+// DurationDistribution. A value of another type is not delivered; an error
+// event is logged instead. Metrics defined outside this package accept any
+// type.
+//
+// A value observed for a Counter is added to the count, as with
+// Counter.Record, so a callback that reads a running total must report the
+// increase since the previous collection rather than the total itself.
+type Observer struct {
+	ctx context.Context
+	at  time.Time
+}
+
+// Int64 delivers a metric event for m with the value v and the given labels.
+func (o *Observer) Int64(m Metric, v int64, labels ...Label) {
+	if o.accepts(m, "int64") {
+		o.observe(m, Int64(string(MetricVal), v), labels)
+	}
+}
+
+// Float64 delivers a metric event for m with the value v and the given
+// labels.
+func (o *Observer) Float64(m Metric, v float64, labels ...Label) {
+	if o.accepts(m, "float64") {
+		o.observe(m, Float64(string(MetricVal), v), labels)
+	}
+}
+
+// Duration delivers a metric event for m with the value v and the given
+// labels.
+func (o *Observer) Duration(m Metric, v time.Duration, labels ...Label) {
+	if o.accepts(m, "time.Duration") {
+		o.observe(m, Duration(string(MetricVal), v), labels)
+	}
+}
+
+// accepts reports whether m records values of type typ, and logs an error
+// event if it does not.
+func (o *Observer) accepts(m Metric, typ string) bool {
+	var want string
+	switch m.(type) {
+	case *Counter, *IntDistribution:
+		want = "int64"
+	case *FloatGauge:
+		want = "float64"
+	case *DurationDistribution:
+		want = "time.Duration"
+	default:
+		return true
+	}
+	if typ == want {
+		return true
+	}
+	Error(o.ctx, "observed a metric value of the wrong type",
+		fmt.Errorf("metric %q records %s values, not %s", m.Name(), want, typ))
+	return false
+}
+
+func (o *Observer) observe(m Metric, v Label, labels []Label) {
+	ev := New(o.ctx, MetricKind)
+	if ev != nil {
+		ev.At = o.at
+		record(ev, m, v)
+		ev.Labels = append(ev.Labels, labels...)
+		ev.Deliver()
+	}
+}
+
+// callbackEntry is a registered Callback; its address identifies the
+// registration.
+type callbackEntry struct {
+	f Callback
+}
+
+// RegisterCallback adds f to the callbacks called by Collect, and returns a
+// function that removes it again.
+func (e *Exporter) RegisterCallback(f Callback) (unregister func()) {
+	entry := &callbackEntry{f: f}
+	e.callbackMu.Lock()
+	e.callbacks = append(e.callbacks, entry)
+	e.callbackMu.Unlock()
+	return func() {
+		e.callbackMu.Lock()
+		defer e.callbackMu.Unlock()
+		for i, c := range e.callbacks {
+			if c == entry {
+				e.callbacks = append(e.callbacks[:i:i], e.callbacks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Collect calls the registered callbacks in the order they were registered,
+// delivering the values they observe to the exporter.
+// The events are stamped with a single time from ExporterOptions.Now, and are
+// subject to the policies of the exporter like other metric events.
+// Pull-based handlers can call Collect when they are asked for metrics;
+// otherwise see CollectEvery.
+func (e *Exporter) Collect(ctx context.Context) {
+	e.callbackMu.Lock()
+	callbacks := e.callbacks
+	e.callbackMu.Unlock()
+	if len(callbacks) == 0 {
+		return
+	}
+	o := &Observer{ctx: WithExporter(ctx, e), at: e.opts.Now()}
+	for _, c := range callbacks {
+		c.f(ctx, o)
+	}
+}
+
+// CollectEvery calls Collect every interval d until ctx is done.
+// The intervals are timed with ExporterOptions.After, and start when the
+// previous collection is over.
+func (e *Exporter) CollectEvery(ctx context.Context, d time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.opts.After(d):
+			e.Collect(ctx)
+		}
+	}
+}
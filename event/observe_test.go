@@ -0,0 +1,147 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package event_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/eventtest"
+)
+
+func TestCollect(t *testing.T) {
+	h := &eventtest.CaptureHandler{}
+	e := event.NewExporter(h, eventtest.ExporterOptions())
+	ctx := context.Background()
+
+	queued := event.NewFloatGauge("queued", nil)
+	workers := event.NewCounter("workers", nil)
+	queues := map[string]float64{"high": 2, "low": 7}
+	unregister := e.RegisterCallback(func(ctx context.Context, o *event.Observer) {
+		o.Float64(queued, queues["high"], event.String("queue", "high"))
+		o.Float64(queued, queues["low"], event.String("queue", "low"))
+	})
+	e.RegisterCallback(func(ctx context.Context, o *event.Observer) {
+		o.Int64(workers, 4)
+	})
+
+	e.Collect(ctx)
+	queues["high"] = 0
+	e.Collect(ctx)
+
+	type observation struct {
+		At     time.Time
+		Metric string
+		Value  interface{}
+		Queue  string
+	}
+	var got []observation
+	for _, ev := range h.Got {
+		if ev.Kind != event.MetricKind {
+			t.Fatalf("got %v event, want metric", ev.Kind)
+		}
+		m, _ := event.MetricKey.Find(&ev)
+		queue := ""
+		if l := ev.Find("queue"); l.HasValue() {
+			queue = l.String()
+		}
+		got = append(got, observation{
+			At:     ev.At,
+			Metric: m.(event.Metric).Name(),
+			Value:  ev.Find(string(event.MetricVal)).Interface(),
+			Queue:  queue,
+		})
+	}
+	t0 := eventtest.InitialTime
+	t1 := t0.Add(time.Second)
+	want := []observation{
+		{t0, "queued", 2.0, "high"},
+		{t0, "queued", 7.0, "low"},
+		{t0, "workers", int64(4), ""},
+		{t1, "queued", 0.0, "high"},
+		{t1, "queued", 7.0, "low"},
+		{t1, "workers", int64(4), ""},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	h.Reset()
+	unregister()
+	unregister() // a second call does nothing
+	e.Collect(ctx)
+	if len(h.Got) != 1 || h.Got[0].Find(string(event.MetricKey)).Interface() != workers {
+		t.Errorf("after unregistering, got %d events, want the workers metric only", len(h.Got))
+	}
+
+	h.Reset()
+	e.SetPolicy("", event.Policy{DisableMetrics: true})
+	e.Collect(ctx)
+	if len(h.Got) != 0 {
+		t.Errorf("got %d events with metrics disabled, want 0", len(h.Got))
+	}
+}
+
+func TestCollectEvery(t *testing.T) {
+	ticks := make(chan time.Time)
+	opts := eventtest.ExporterOptions()
+	opts.After = func(d time.Duration) <-chan time.Time {
+		if d != time.Minute {
+			t.Errorf("waiting %v, want %v", d, time.Minute)
+		}
+		return ticks
+	}
+	e := event.NewExporter(&eventtest.CaptureHandler{}, opts)
+	calls := make(chan struct{})
+	e.RegisterCallback(func(ctx context.Context, o *event.Observer) {
+		calls <- struct{}{}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.CollectEvery(ctx, time.Minute)
+		close(done)
+	}()
+	for i := 0; i < 2; i++ {
+		ticks <- eventtest.InitialTime
+		<-calls
+	}
+	cancel()
+	<-done
+	select {
+	case <-calls:
+		t.Error("collected after the context was done")
+	default:
+	}
+}
+
+func TestCollectWrongType(t *testing.T) {
+	h := &eventtest.CaptureHandler{}
+	e := event.NewExporter(h, eventtest.ExporterOptions())
+	gauge := event.NewFloatGauge("gauge", nil)
+	counter := event.NewCounter("counter", nil)
+	latency := event.NewDuration("latency", nil)
+	e.RegisterCallback(func(ctx context.Context, o *event.Observer) {
+		o.Int64(gauge, 1)
+		o.Float64(counter, 2)
+		o.Duration(counter, 3)
+		o.Int64(latency, 4)
+		o.Float64(gauge, 5)
+	})
+	e.Collect(context.Background())
+	var kinds []event.Kind
+	for _, ev := range h.Got {
+		kinds = append(kinds, ev.Kind)
+	}
+	want := []event.Kind{event.LogKind, event.LogKind, event.LogKind, event.LogKind, event.MetricKind}
+	if diff := cmp.Diff(want, kinds); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import "time"
+
+// ExporterOptions configures an Exporter.
+type ExporterOptions struct {
+	// If non-nil, sets zero Event.At on delivery.
+	Now func() time.Time
+
+	// If non-nil, replaces time.After for the intervals of
+	// Exporter.CollectEvery.
+	After func(d time.Duration) <-chan time.Time
+
+	// Disable some event types, for better performance.
+	// These form the initial default Policy of the exporter, which can be
+	// changed later with Exporter.SetPolicy.
+	DisableLogging     bool
+	DisableTracing     bool
+	DisableAnnotations bool
+	DisableMetrics     bool
+
+	// Enable automatically setting the event Namespace to the calling package's
+	// import path.
+	EnableNamespaces bool
+
+	// Enable capturing the call stack and the unwrapped error chain on events
+	// created by Error. See ErrorStack and ErrorCauses.
+	EnableErrorStacks bool
+	EnableErrorCauses bool
+
+	// If non-nil, decides which events are created. See Sampler for details.
+	Sampler Sampler
+}
+
+// Policy controls which events an Exporter creates for a namespace.
+// The zero Policy enables all events.
+// See Exporter.SetPolicy.
+type Policy struct {
+	DisableLogging     bool
+	DisableTracing     bool
+	DisableAnnotations bool
+	DisableMetrics     bool
+
+	// If non-zero, log events with a severity level below MinLevel are
+	// dropped when they are delivered.
+	// The level is read from the "level" label, as added by package severity.
+	MinLevel uint64
+}
+
+// Source describes where an event came from.
+type Source struct {
+	Space string
+	Owner string
+	Name  string
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otel
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/severity"
+)
+
+// A LogRecord is a log event, with the fields of the OpenTelemetry log data
+// model.
+// See https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/logs/data-model.md.
+type LogRecord struct {
+	Timestamp time.Time
+
+	// The span that was current when the event was logged, if any.
+	TraceID    trace.TraceID
+	SpanID     trace.SpanID
+	TraceFlags trace.TraceFlags
+
+	SeverityText   string
+	SeverityNumber int // 0 if the event has no level
+
+	Body       string
+	Attributes []attribute.KeyValue
+
+	// Scope is the instrumentation scope of the event, from Options.Scope.
+	Scope string
+}
+
+// A LogExporter sends log records to a destination.
+type LogExporter interface {
+	ExportLog(ctx context.Context, r LogRecord)
+}
+
+// LogExporterFunc is an adapter to allow the use of ordinary functions as
+// LogExporters.
+type LogExporterFunc func(ctx context.Context, r LogRecord)
+
+// ExportLog calls f(ctx, r).
+func (f LogExporterFunc) ExportLog(ctx context.Context, r LogRecord) { f(ctx, r) }
+
+// SpanEvents returns a LogExporter that adds each record as an event of the
+// span in the context, named after the body of the record.
+// The severity is recorded in the "log.severity" attribute.
+// Records logged outside a recording span are dropped.
+func SpanEvents() LogExporter {
+	return LogExporterFunc(func(ctx context.Context, r LogRecord) {
+		span := trace.SpanFromContext(ctx)
+		if !span.IsRecording() {
+			return
+		}
+		attrs := r.Attributes
+		if r.SeverityText != "" {
+			attrs = append(attrs, attribute.String("log.severity", r.SeverityText))
+		}
+		span.AddEvent(r.Body, trace.WithTimestamp(r.Timestamp), trace.WithAttributes(attrs...))
+	})
+}
+
+type LogHandler struct {
+	exporter LogExporter
+	opts     Options
+}
+
+// NewLogHandler returns a handler that turns Log events into log records and
+// sends them to e.
+// The "msg" label becomes the body of the record, and the "level" label its
+// severity; the other labels become attributes.
+func NewLogHandler(e LogExporter, opts *Options) *LogHandler {
+	h := &LogHandler{exporter: e}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *LogHandler) Event(ctx context.Context, ev *event.Event) context.Context {
+	if ev.Kind != event.LogKind {
+		return ctx
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	r := LogRecord{
+		Timestamp:  ev.At,
+		TraceID:    sc.TraceID(),
+		SpanID:     sc.SpanID(),
+		TraceFlags: sc.TraceFlags(),
+		Body:       ev.Find("msg").String(),
+		Attributes: h.opts.attributes(nil, ev.Labels, "msg", severity.Key),
+		Scope:      h.opts.scope(ev.Source),
+	}
+	if l := ev.Find(severity.Key); l.HasValue() {
+		level := labelLevel(l)
+		r.SeverityNumber = int(level)
+		r.SeverityText = level.String()
+	}
+	h.exporter.ExportLog(ctx, r)
+	return ctx
+}
+
+// labelLevel returns the severity in a "level" label, which may hold a
+// severity.Level or a plain integer.
+func labelLevel(l event.Label) severity.Level {
+	switch {
+	case l.IsUint64():
+		return severity.Level(l.Uint64())
+	case l.IsInt64():
+		if v := l.Int64(); v > 0 {
+			return severity.Level(v)
+		}
+		return 0
+	}
+	if level, ok := l.Interface().(severity.Level); ok {
+		return level
+	}
+	return 0
+}
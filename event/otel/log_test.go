@@ -0,0 +1,124 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package otel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/otel"
+	"golang.org/x/exp/event/severity"
+)
+
+const testScope = "golang.org/x/exp/event/otel_test"
+
+func TestLogRecords(t *testing.T) {
+	var got []otel.LogRecord
+	le := otel.LogExporterFunc(func(_ context.Context, r otel.LogRecord) { got = append(got, r) })
+	opts := &otel.Options{Scope: func(s event.Source) string { return s.Space }}
+	tp := sdktrace.NewTracerProvider()
+	th := otel.NewTraceHandler(tp.Tracer("test"))
+	ctx := event.WithExporter(context.Background(), event.NewExporter(handlers{th, otel.NewLogHandler(le, opts)}, &event.ExporterOptions{EnableNamespaces: true}))
+
+	severity.Info.Log(ctx, "outside")
+	sctx := event.Start(ctx, "span")
+	severity.Warning.Log(sctx, "inside", event.Int64("count", 3), event.Group("req", event.String("method", "GET")))
+	event.Log(sctx, "no level")
+	sc := trace.SpanContextFromContext(sctx)
+	event.End(sctx)
+
+	if len(got) != 3 {
+		t.Fatalf("got %d records, want 3", len(got))
+	}
+	if got[0].TraceID.IsValid() || got[0].SpanID.IsValid() {
+		t.Errorf("record outside a span has trace %s, span %s", got[0].TraceID, got[0].SpanID)
+	}
+	r := got[1]
+	if r.TraceID != sc.TraceID() || r.SpanID != sc.SpanID() || r.TraceFlags != sc.TraceFlags() {
+		t.Errorf("got trace %s, span %s, want %s, %s", r.TraceID, r.SpanID, sc.TraceID(), sc.SpanID())
+	}
+	if r.Body != "inside" || r.SeverityNumber != 13 || r.SeverityText != "warning" || r.Scope != testScope {
+		t.Errorf("got body %q, severity %d %q, scope %q", r.Body, r.SeverityNumber, r.SeverityText, r.Scope)
+	}
+	if r.Timestamp.IsZero() {
+		t.Error("record has no timestamp")
+	}
+	want := []attribute.KeyValue{attribute.Int64("count", 3), attribute.String("req.method", "GET")}
+	if diff := cmp.Diff(want, r.Attributes, cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Errorf("attributes mismatch (-want, +got):\n%s", diff)
+	}
+	if r := got[2]; r.SeverityNumber != 0 || r.SeverityText != "" {
+		t.Errorf("got severity %d %q for an event without a level", r.SeverityNumber, r.SeverityText)
+	}
+}
+
+func TestSpanEvents(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	opts := &otel.Options{
+		Scope:          func(s event.Source) string { return s.Space },
+		TracerProvider: tp,
+		SpanAttributes: true,
+		AttributeKeys:  map[string]attribute.Key{"error": semconv.ExceptionMessageKey},
+		Filter:         func(l event.Label) bool { return l.Name != "password" },
+	}
+	th := otel.NewTraceHandlerWithOptions(tp.Tracer("test"), opts)
+	lh := otel.NewLogHandler(otel.SpanEvents(), opts)
+	ctx := event.WithExporter(context.Background(), event.NewExporter(handlers{th, lh}, &event.ExporterOptions{EnableNamespaces: true}))
+
+	// no span, so no event
+	event.Log(ctx, "dropped")
+	sctx := event.Start(ctx, "login", event.String("user", "gopher"), event.String("password", "secret"))
+	event.Error(sctx, "login failed", errors.New("bad password"), event.String("password", "secret"))
+	event.End(sctx, event.Bool("ok", false))
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	s := spans[0]
+	if got := s.InstrumentationLibrary().Name; got != testScope {
+		t.Errorf("got scope %q, want %q", got, testScope)
+	}
+	wantAttrs := []attribute.KeyValue{attribute.String("user", "gopher"), attribute.Bool("ok", false)}
+	if diff := cmp.Diff(wantAttrs, s.Attributes(), cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Errorf("span attributes mismatch (-want, +got):\n%s", diff)
+	}
+	events := s.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d span events, want 1: %v", len(events), events)
+	}
+	if events[0].Name != "login failed" {
+		t.Errorf("got span event %q, want %q", events[0].Name, "login failed")
+	}
+	wantAttrs = []attribute.KeyValue{semconv.ExceptionMessageKey.String("bad password")}
+	if diff := cmp.Diff(wantAttrs, events[0].Attributes, cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Errorf("span event attributes mismatch (-want, +got):\n%s", diff)
+	}
+}
+
+func TestSpanAttributesDefault(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	ctx := event.WithExporter(context.Background(), event.NewExporter(otel.NewTraceHandler(tp.Tracer("test")), nil))
+	event.End(event.Start(ctx, "login", event.String("user", "gopher")), event.Bool("ok", false))
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if attrs := spans[0].Attributes(); len(attrs) != 0 {
+		t.Errorf("got span attributes %v without SpanAttributes", attrs)
+	}
+}
@@ -8,11 +8,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/bits"
 	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	otelunit "go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/event"
 )
 
@@ -20,23 +23,44 @@ import (
 // Its Event method handles Metric events and ignores all others.
 type MetricHandler struct {
 	meter metric.MeterMust
+	opts  Options
 	mu    sync.Mutex
 	// A map from event.Metrics to, effectively, otel Meters.
 	// But since the only thing we need from the Meter is recording a value, we
 	// use a function for that that closes over the Meter itself.
 	recordFuncs map[event.Metric]recordFunc
+	boundaries  map[string][]float64 // by instrument name
+	exemplars   *event.ExemplarReservoir
 }
 
+// exemplarsPerBucket is the number of exemplars a MetricHandler retains for
+// each bucket of a metric.
+const exemplarsPerBucket = 4
+
 type recordFunc func(context.Context, event.Label, []attribute.KeyValue)
 
 var _ event.Handler = (*MetricHandler)(nil)
 
-// NewMetricHandler creates a new MetricHandler.
+// NewMetricHandler creates a new MetricHandler, that records metrics with
+// instruments of m.
 func NewMetricHandler(m metric.Meter) *MetricHandler {
-	return &MetricHandler{
+	return NewMetricHandlerWithOptions(m, nil)
+}
+
+// NewMetricHandlerWithOptions is like NewMetricHandler, but metrics are
+// recorded with the meter for the scope of their first event if opts maps it
+// to one, and their attributes follow opts.
+func NewMetricHandlerWithOptions(m metric.Meter, opts *Options) *MetricHandler {
+	h := &MetricHandler{
 		meter:       metric.Must(m),
 		recordFuncs: map[event.Metric]recordFunc{},
+		boundaries:  map[string][]float64{},
+		exemplars:   event.NewExemplarReservoir(exemplarsPerBucket),
 	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
 }
 
 func (m *MetricHandler) Event(ctx context.Context, e *event.Event) context.Context {
@@ -54,78 +78,191 @@ func (m *MetricHandler) Event(ctx context.Context, e *event.Event) context.Conte
 	if !lval.HasValue() {
 		panic(errors.New("no metric value for metric event"))
 	}
-	rf := m.getRecordFunc(em)
+	rf := m.getRecordFunc(em, e.Source)
 	if rf == nil {
 		panic(fmt.Errorf("unable to record for metric %v", em))
 	}
-	rf(ctx, lval, labelsToAttributes(e.Labels))
+	rf(ctx, lval, m.opts.attributes(nil, e.Labels, string(event.MetricKey), string(event.MetricVal)))
+	m.offerExemplar(ctx, em, e)
 	return ctx
 }
 
-func (m *MetricHandler) getRecordFunc(em event.Metric) recordFunc {
+// Exemplars returns the most recent exemplars of em, indexed by bucket.
+// Exemplars are only kept for values recorded inside a trace. If the context
+// also held an OpenTelemetry span, the exemplar has "trace_id" and "span_id"
+// labels identifying it.
+//
+// Values of distributions are bucketed by the Buckets of the metric, or if it
+// has none by the position of their highest set bit; other metrics have a
+// single bucket, 0.
+func (m *MetricHandler) Exemplars(em event.Metric) map[int][]event.Exemplar {
+	return m.exemplars.Exemplars(em)
+}
+
+func (m *MetricHandler) offerExemplar(ctx context.Context, em event.Metric, e *event.Event) {
+	ex, ok := event.ExemplarOf(e)
+	if !ok {
+		return
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		ex.Labels = []event.Label{
+			event.String("trace_id", sc.TraceID().String()),
+			event.String("span_id", sc.SpanID().String()),
+		}
+	}
+	bucket := 0
+	buckets := em.Options().Buckets
+	switch em.(type) {
+	case *event.DurationDistribution:
+		bucket = bucketIndex(buckets, int64(ex.Value.Duration()))
+	case *event.IntDistribution:
+		bucket = bucketIndex(buckets, ex.Value.Int64())
+	}
+	m.exemplars.Offer(em, bucket, ex)
+}
+
+func bucketIndex(b *event.Buckets, v int64) int {
+	if b == nil {
+		return highBit(v)
+	}
+	return b.Index(float64(v))
+}
+
+func highBit(v int64) int {
+	if v <= 0 {
+		return 0
+	}
+	return bits.Len64(uint64(v))
+}
+
+// Boundaries returns the bucket boundaries of the histogram instrument with
+// the given name, in the unit its values are recorded in, or nil if its
+// metric has no Buckets or has not been recorded yet.
+// The metric API has no way to pass boundaries to the SDK, so this is meant to
+// be called from the aggregator selector of an SDK, which creates the
+// aggregators of an instrument after the instrument itself.
+func (m *MetricHandler) Boundaries(instrument string) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.boundaries[instrument]...)
+}
+
+func (m *MetricHandler) getRecordFunc(em event.Metric, source event.Source) recordFunc {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if f, ok := m.recordFuncs[em]; ok {
 		return f
 	}
-	f := m.newRecordFunc(em)
+	meter := m.meter
+	if scope := m.opts.scope(source); scope != "" && m.opts.MeterProvider != nil {
+		meter = metric.Must(m.opts.MeterProvider.Meter(scope))
+	}
+	opts := em.Options()
+	name := opts.Namespace + "/" + em.Name()
+	f, histogram := newRecordFunc(meter, name, em)
+	if histogram && opts.Buckets != nil {
+		bounds := opts.Buckets.Bounds()
+		if _, ok := em.(*event.DurationDistribution); ok {
+			scale := durationScale(opts.Unit)
+			for i := range bounds {
+				bounds[i] /= scale
+			}
+		}
+		m.boundaries[name] = bounds
+	}
 	m.recordFuncs[em] = f
 	return f
 }
 
-func (m *MetricHandler) newRecordFunc(em event.Metric) recordFunc {
+// newRecordFunc returns the function that records the values of em with a
+// new instrument called name, and whether that instrument is a histogram.
+// The instrument is chosen from the kind of em and its Aggregation hint.
+// The metric API has no synchronous instrument that keeps the last value, so
+// AggregateLastValue is treated like AggregateDefault.
+func newRecordFunc(meter metric.MeterMust, name string, em event.Metric) (recordFunc, bool) {
 	opts := em.Options()
-	name := opts.Namespace + "/" + em.Name()
 	otelOpts := []metric.InstrumentOption{
 		metric.WithDescription(opts.Description),
 		metric.WithUnit(otelunit.Unit(opts.Unit)), // cast OK: same strings
 	}
 	switch em.(type) {
 	case *event.Counter:
-		c := m.meter.NewInt64Counter(name, otelOpts...)
+		if opts.Aggregation == event.AggregateHistogram {
+			return int64Histogram(meter.NewInt64Histogram(name, otelOpts...)), true
+		}
+		c := meter.NewInt64Counter(name, otelOpts...)
 		return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
 			c.Add(ctx, l.Int64(), attrs...)
-		}
+		}, false
 
 	case *event.FloatGauge:
-		g := m.meter.NewFloat64UpDownCounter(name, otelOpts...)
+		if opts.Aggregation == event.AggregateHistogram {
+			h := meter.NewFloat64Histogram(name, otelOpts...)
+			return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
+				h.Record(ctx, l.Float64(), attrs...)
+			}, true
+		}
+		g := meter.NewFloat64UpDownCounter(name, otelOpts...)
 		return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
 			g.Add(ctx, l.Float64(), attrs...)
-		}
+		}, false
 
 	case *event.DurationDistribution:
-		r := m.meter.NewInt64Histogram(name, otelOpts...)
+		scale := durationScale(opts.Unit)
+		if opts.Aggregation == event.AggregateSum {
+			if scale == 1 {
+				c := meter.NewInt64Counter(name, otelOpts...)
+				return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
+					c.Add(ctx, l.Duration().Nanoseconds(), attrs...)
+				}, false
+			}
+			c := meter.NewFloat64Counter(name, otelOpts...)
+			return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
+				c.Add(ctx, float64(l.Duration())/scale, attrs...)
+			}, false
+		}
+		if scale == 1 {
+			r := meter.NewInt64Histogram(name, otelOpts...)
+			return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
+				r.Record(ctx, l.Duration().Nanoseconds(), attrs...)
+			}, true
+		}
+		r := meter.NewFloat64Histogram(name, otelOpts...)
 		return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
-			r.Record(ctx, l.Duration().Nanoseconds(), attrs...)
+			r.Record(ctx, float64(l.Duration())/scale, attrs...)
+		}, true
+
+	case *event.IntDistribution:
+		if opts.Aggregation == event.AggregateSum {
+			c := meter.NewInt64Counter(name, otelOpts...)
+			return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
+				c.Add(ctx, l.Int64(), attrs...)
+			}, false
 		}
+		return int64Histogram(meter.NewInt64Histogram(name, otelOpts...)), true
 
 	default:
-		return nil
+		return nil, false
 	}
 }
 
-func labelsToAttributes(ls []event.Label) []attribute.KeyValue {
-	var attrs []attribute.KeyValue
-	for _, l := range ls {
-		if l.Name == string(event.MetricKey) || l.Name == string(event.MetricVal) {
-			continue
-		}
-		attrs = append(attrs, labelToAttribute(l))
+func int64Histogram(h metric.Int64Histogram) recordFunc {
+	return func(ctx context.Context, l event.Label, attrs []attribute.KeyValue) {
+		h.Record(ctx, l.Int64(), attrs...)
 	}
-	return attrs
 }
 
-func labelToAttribute(l event.Label) attribute.KeyValue {
-	switch {
-	case l.IsString():
-		return attribute.String(l.Name, l.String())
-	case l.IsInt64():
-		return attribute.Int64(l.Name, l.Int64())
-	case l.IsFloat64():
-		return attribute.Float64(l.Name, l.Float64())
-	case l.IsBool():
-		return attribute.Bool(l.Name, l.Bool())
-	default: // including uint64
-		panic(fmt.Errorf("cannot convert label value of type %T to attribute.KeyValue", l.Interface()))
+// durationScale returns the number of nanoseconds in unit, which is 1 for
+// units other than time units.
+func durationScale(unit event.Unit) float64 {
+	switch unit {
+	case event.UnitMicroseconds:
+		return float64(time.Microsecond)
+	case event.UnitMilliseconds:
+		return float64(time.Millisecond)
+	case event.UnitSeconds:
+		return float64(time.Second)
+	default:
+		return 1
 	}
 }
@@ -2,6 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build !disable_events
+
 package otel_test
 
 import (
@@ -13,6 +15,10 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric/metrictest"
 	"go.opentelemetry.io/otel/metric/number"
+	"go.opentelemetry.io/otel/metric/sdkapi"
+	otelunit "go.opentelemetry.io/otel/metric/unit"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/event"
 	"golang.org/x/exp/event/otel"
 )
@@ -73,3 +79,115 @@ func recordMetrics(ctx context.Context) {
 	d.Record(ctx, 1248*time.Millisecond)
 	d.Record(ctx, 1255*time.Millisecond)
 }
+
+func TestExemplars(t *testing.T) {
+	mp := metrictest.NewMeterProvider()
+	mh := otel.NewMetricHandler(mp.Meter("test"))
+	tp := sdktrace.NewTracerProvider()
+	th := otel.NewTraceHandler(tp.Tracer("test"))
+	ctx := event.WithExporter(context.Background(), event.NewExporter(handlers{th, mh}, nil))
+
+	d := event.NewDuration("latency", nil)
+	d.Record(ctx, time.Millisecond)
+	sctx := event.Start(ctx, "span")
+	d.Record(sctx, 3*time.Second)
+	sc := trace.SpanContextFromContext(sctx)
+	event.End(sctx)
+
+	got := mh.Exemplars(d)
+	if len(got) != 1 {
+		t.Fatalf("got %d buckets, want 1: %v", len(got), got)
+	}
+	for _, exs := range got {
+		if len(exs) != 1 {
+			t.Fatalf("got %d exemplars, want 1", len(exs))
+		}
+		want := []event.Label{
+			event.String("trace_id", sc.TraceID().String()),
+			event.String("span_id", sc.SpanID().String()),
+		}
+		if diff := cmp.Diff(want, exs[0].Labels, cmp.Comparer(event.Label.Equal)); diff != "" {
+			t.Errorf("mismatch (-want, got):\n%s", diff)
+		}
+		if v := exs[0].Value.Duration(); v != 3*time.Second {
+			t.Errorf("got value %v, want 3s", v)
+		}
+	}
+}
+
+// handlers delivers each event to all of its handlers in turn.
+type handlers []event.Handler
+
+func (hs handlers) Event(ctx context.Context, ev *event.Event) context.Context {
+	for _, h := range hs {
+		ctx = h.Event(ctx, ev)
+	}
+	return ctx
+}
+
+func TestMeterOptions(t *testing.T) {
+	mp := metrictest.NewMeterProvider()
+	mh := otel.NewMetricHandler(mp.Meter("test"))
+	tp := sdktrace.NewTracerProvider()
+	th := otel.NewTraceHandler(tp.Tracer("test"))
+	ctx := event.WithExporter(context.Background(), event.NewExporter(handlers{th, mh}, nil))
+
+	latency := event.NewDuration("latency_ms", &event.MetricOptions{
+		Unit:    event.UnitMilliseconds,
+		Buckets: event.DurationBuckets(time.Millisecond, 10*time.Millisecond, 100*time.Millisecond),
+	})
+	sizes := event.NewIntDistribution("sizes", &event.MetricOptions{Unit: event.UnitBytes})
+	total := event.NewIntDistribution("total", &event.MetricOptions{Aggregation: event.AggregateSum})
+	hits := event.NewCounter("hits", &event.MetricOptions{
+		Aggregation: event.AggregateHistogram,
+		Buckets:     event.LinearBuckets(1, 1, 3),
+	})
+
+	sctx := event.Start(ctx, "span")
+	latency.Record(sctx, 50*time.Millisecond)
+	event.End(sctx)
+	sizes.Record(ctx, 1024)
+	total.Record(ctx, 3)
+	hits.Record(ctx, 2)
+
+	type measured struct {
+		Name  string
+		Kind  sdkapi.InstrumentKind
+		Unit  otelunit.Unit
+		Value interface{}
+	}
+	var got []measured
+	for _, b := range mp.MeasurementBatches {
+		for _, m := range b.Measurements {
+			d := m.Instrument.Descriptor()
+			got = append(got, measured{d.Name(), d.InstrumentKind(), d.Unit(), m.Number.AsInterface(d.NumberKind())})
+		}
+	}
+	const ns = "golang.org/x/exp/event/otel_test/"
+	want := []measured{
+		{ns + "latency_ms", sdkapi.HistogramInstrumentKind, otelunit.Milliseconds, 50.0},
+		{ns + "sizes", sdkapi.HistogramInstrumentKind, otelunit.Bytes, int64(1024)},
+		{ns + "total", sdkapi.CounterInstrumentKind, otelunit.Dimensionless, int64(3)},
+		{ns + "hits", sdkapi.HistogramInstrumentKind, otelunit.Dimensionless, int64(2)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, got):\n%s", diff)
+	}
+
+	for name, want := range map[string][]float64{
+		ns + "latency_ms": {1, 10, 100},
+		ns + "hits":       {1, 2, 3},
+		ns + "sizes":      nil,
+		ns + "total":      nil,
+	} {
+		if diff := cmp.Diff(want, mh.Boundaries(name)); diff != "" {
+			t.Errorf("%s: boundaries mismatch (-want, got):\n%s", name, diff)
+		}
+	}
+
+	// 50ms is in the bucket with bound 100ms
+	exs := mh.Exemplars(latency)
+	if len(exs) != 1 || len(exs[2]) != 1 {
+		t.Errorf("got exemplars %v, want one in bucket 2", exs)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otel
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/event"
+)
+
+// Options configures the handlers of this package.
+// All its fields are optional.
+type Options struct {
+	// Scope returns the name of the instrumentation scope for events from
+	// source, such as its Space. If Scope is nil or returns "", the tracer or
+	// meter passed to the handler is used.
+	// Events only have a source if the exporter was created with
+	// EnableNamespaces set.
+	// The TraceHandler and MetricHandler also need TracerProvider and
+	// MeterProvider respectively to create the tracer or meter of a scope.
+	Scope          func(source event.Source) string
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+
+	// SpanAttributes makes the labels of Start and End events attributes of
+	// their span, except for the ones interpreted by the TraceHandler: "name",
+	// "link", "newRoot" and "spanKind".
+	SpanAttributes bool
+
+	// AttributeKeys translates label names into attribute keys, for example
+	// to follow the OpenTelemetry semantic conventions.
+	// Labels inside groups are named by joining the group names and the label
+	// name with dots.
+	// Names not in the map are used as they are.
+	AttributeKeys map[string]attribute.Key
+
+	// If non-nil, Filter is called for each label that would become an
+	// attribute, and the label is dropped if it returns false.
+	Filter func(event.Label) bool
+}
+
+func (o *Options) scope(source event.Source) string {
+	if o.Scope == nil {
+		return ""
+	}
+	return o.Scope(source)
+}
+
+// attributes appends to attrs the attributes for the labels that are not
+// named in skip.
+func (o *Options) attributes(attrs []attribute.KeyValue, ls []event.Label, skip ...string) []attribute.KeyValue {
+outer:
+	for _, l := range ls {
+		for _, s := range skip {
+			if l.Name == s {
+				continue outer
+			}
+		}
+		attrs = o.appendAttribute(attrs, "", l)
+	}
+	return attrs
+}
+
+func (o *Options) appendAttribute(attrs []attribute.KeyValue, prefix string, l event.Label) []attribute.KeyValue {
+	if l.Name == "" || (o.Filter != nil && !o.Filter(l)) {
+		return attrs
+	}
+	name := prefix + l.Name
+	if l.IsGroup() {
+		for _, c := range l.Group() {
+			attrs = o.appendAttribute(attrs, name+".", c)
+		}
+		return attrs
+	}
+	key, ok := o.AttributeKeys[name]
+	if !ok {
+		key = attribute.Key(name)
+	}
+	return append(attrs, labelToAttribute(key, l))
+}
+
+// labelToAttribute converts the value of a label, which must not be a group.
+// Values without a matching attribute type become strings.
+func labelToAttribute(key attribute.Key, l event.Label) attribute.KeyValue {
+	switch {
+	case !l.HasValue():
+		return key.Bool(true)
+	case l.IsString():
+		return key.String(l.String())
+	case l.IsInt64():
+		return key.Int64(l.Int64())
+	case l.IsUint64():
+		if u := l.Uint64(); u <= 1<<63-1 {
+			return key.Int64(int64(u))
+		}
+		return key.String(strconv.FormatUint(l.Uint64(), 10))
+	case l.IsFloat64():
+		return key.Float64(l.Float64())
+	case l.IsBool():
+		return key.Bool(l.Bool())
+	case l.IsDuration():
+		return key.String(l.Duration().String())
+	case l.IsBytes():
+		return key.String(string(l.Bytes()))
+	}
+	switch v := l.Interface().(type) {
+	case nil:
+		return key.String("<nil>")
+	case error:
+		return key.String(v.Error())
+	case fmt.Stringer:
+		return key.String(v.String())
+	default:
+		return key.String(fmt.Sprint(v))
+	}
+}
@@ -6,6 +6,7 @@ package otel
 
 import (
 	"context"
+	"sync"
 
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/event"
@@ -13,10 +14,27 @@ import (
 
 type TraceHandler struct {
 	tracer trace.Tracer
+	opts   Options
+
+	mu      sync.Mutex
+	tracers map[string]trace.Tracer // by instrumentation scope
 }
 
+// NewTraceHandler returns a handler that turns Start and End events into
+// spans of t.
 func NewTraceHandler(t trace.Tracer) *TraceHandler {
-	return &TraceHandler{tracer: t}
+	return NewTraceHandlerWithOptions(t, nil)
+}
+
+// NewTraceHandlerWithOptions is like NewTraceHandler, but spans are started
+// with the tracer for the scope of the event if opts maps it to one, and
+// labels become span attributes if opts.SpanAttributes is set.
+func NewTraceHandlerWithOptions(t trace.Tracer, opts *Options) *TraceHandler {
+	h := &TraceHandler{tracer: t}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
 }
 
 type spanKey struct{}
@@ -25,13 +43,23 @@ func (t *TraceHandler) Event(ctx context.Context, ev *event.Event) context.Conte
 	switch ev.Kind {
 	case event.StartKind:
 		name, opts := labelsToSpanStartOptions(ev.Labels)
-		octx, span := t.tracer.Start(ctx, name, opts...)
+		if t.opts.SpanAttributes {
+			if attrs := t.opts.attributes(nil, ev.Labels, "name", "link", "newRoot", "spanKind", string(event.DurationMetric)); len(attrs) > 0 {
+				opts = append(opts, trace.WithAttributes(attrs...))
+			}
+		}
+		octx, span := t.scopeTracer(ev.Source).Start(ctx, name, opts...)
 		return context.WithValue(octx, spanKey{}, span)
 	case event.EndKind:
 		span, ok := ctx.Value(spanKey{}).(trace.Span)
 		if !ok {
 			panic("End called on context with no span")
 		}
+		if t.opts.SpanAttributes {
+			if attrs := t.opts.attributes(nil, ev.Labels); len(attrs) > 0 {
+				span.SetAttributes(attrs...)
+			}
+		}
 		span.End()
 		return ctx
 	default:
@@ -39,6 +67,25 @@ func (t *TraceHandler) Event(ctx context.Context, ev *event.Event) context.Conte
 	}
 }
 
+// scopeTracer returns the tracer for events from source.
+func (t *TraceHandler) scopeTracer(source event.Source) trace.Tracer {
+	scope := t.opts.scope(source)
+	if scope == "" || t.opts.TracerProvider == nil {
+		return t.tracer
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr, ok := t.tracers[scope]
+	if !ok {
+		if t.tracers == nil {
+			t.tracers = map[string]trace.Tracer{}
+		}
+		tr = t.opts.TracerProvider.Tracer(scope)
+		t.tracers[scope] = tr
+	}
+	return tr
+}
+
 func labelsToSpanStartOptions(ls []event.Label) (string, []trace.SpanStartOption) {
 	var opts []trace.SpanStartOption
 	var name string
@@ -0,0 +1,144 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package event
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+)
+
+// levelKey is the name of the label that holds the severity of an event.
+// It must match severity.Key.
+const levelKey = "level"
+
+// policies is an immutable set of policies.
+// It is replaced as a whole when a policy changes, so that events can be
+// checked against it without locking.
+type policies struct {
+	def    Policy
+	spaces map[string]Policy
+}
+
+// SetPolicy sets the policy for events whose namespace is namespace, or a
+// package path below it. If several policies match, the one with the longest
+// namespace is used. The empty namespace sets the default policy, used when no
+// other policy matches.
+//
+// Policies are checked when an event is created. The namespace of the event
+// is found from the call stack, as for ExporterOptions.EnableNamespaces, so
+// once any namespace policy is set every event pays for a stack lookup.
+// A Start event dropped by a policy is treated like one dropped by the
+// Sampler: the matching End is dropped too, even if the policy changes in
+// between.
+//
+// SetPolicy may be called at any time, including while events are being
+// created.
+func (e *Exporter) SetPolicy(namespace string, p Policy) {
+	e.updatePolicies(func(ps *policies) {
+		if namespace == "" {
+			ps.def = p
+			return
+		}
+		ps.spaces[namespace] = p
+	})
+}
+
+// RemovePolicy removes the policy for namespace, so that events in it are
+// controlled by the policy of an enclosing namespace or the default policy.
+// The default policy cannot be removed; RemovePolicy("") resets it to the
+// zero Policy.
+func (e *Exporter) RemovePolicy(namespace string) {
+	e.updatePolicies(func(ps *policies) {
+		if namespace == "" {
+			ps.def = Policy{}
+			return
+		}
+		delete(ps.spaces, namespace)
+	})
+}
+
+// Policy returns the policy that applies to events in namespace.
+func (e *Exporter) Policy(namespace string) Policy {
+	return e.loadPolicies().lookup(namespace)
+}
+
+func (e *Exporter) loadPolicies() *policies {
+	return (*policies)(atomic.LoadPointer(&e.policies))
+}
+
+// updatePolicies applies f to a copy of the current policies and installs
+// the result.
+func (e *Exporter) updatePolicies(f func(*policies)) {
+	e.policyMu.Lock()
+	defer e.policyMu.Unlock()
+	old := e.loadPolicies()
+	ps := &policies{def: old.def, spaces: make(map[string]Policy, len(old.spaces)+1)}
+	for k, v := range old.spaces {
+		ps.spaces[k] = v
+	}
+	f(ps)
+	atomic.StorePointer(&e.policies, unsafe.Pointer(ps))
+}
+
+// lookup returns the policy for the longest namespace that is space or one of
+// its parent paths.
+func (ps *policies) lookup(space string) Policy {
+	if len(ps.spaces) == 0 {
+		return ps.def
+	}
+	for space != "" {
+		if p, ok := ps.spaces[space]; ok {
+			return p
+		}
+		i := strings.LastIndexByte(space, '/')
+		if i < 0 {
+			break
+		}
+		space = space[:i]
+	}
+	return ps.def
+}
+
+// enabled reports whether the policy allows events of the given kind.
+func (p Policy) enabled(kind Kind) bool {
+	switch kind {
+	case LogKind:
+		return !p.DisableLogging
+	case MetricKind:
+		return !p.DisableMetrics
+	case StartKind, EndKind:
+		return !p.DisableTracing
+	case unknownKind:
+		return !p.DisableAnnotations
+	default:
+		return true
+	}
+}
+
+// belowLevel reports whether ev has a severity level lower than min.
+func (ev *Event) belowLevel(min uint64) bool {
+	l := ev.Find(levelKey)
+	switch {
+	case !l.HasValue():
+		return false
+	case l.IsUint64():
+		return l.Uint64() < min
+	case l.IsInt64():
+		return l.Int64() < int64(min)
+	}
+	// severity.Level is stored as an interface value
+	v := reflect.ValueOf(l.Interface())
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() < min
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() < int64(min)
+	}
+	return false
+}
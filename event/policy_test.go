@@ -0,0 +1,236 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package event_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/eventtest"
+	"golang.org/x/exp/event/severity"
+)
+
+const thisSpace = "golang.org/x/exp/event_test"
+
+func TestPolicy(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		opts   event.ExporterOptions
+		events func(context.Context, *event.Exporter)
+		expect []string
+	}{{
+		name: "options",
+		opts: event.ExporterOptions{DisableLogging: true},
+		events: func(ctx context.Context, e *event.Exporter) {
+			event.Log(ctx, "a")
+			e.SetPolicy("", event.Policy{})
+			event.Log(ctx, "b")
+		},
+		expect: []string{"log b"},
+	}, {
+		name: "namespace",
+		events: func(ctx context.Context, e *event.Exporter) {
+			e.SetPolicy(thisSpace, event.Policy{DisableLogging: true})
+			event.Log(ctx, "a")
+			event.Annotate(ctx, event.String("annotate", "b"))
+			e.RemovePolicy(thisSpace)
+			event.Log(ctx, "c")
+		},
+		expect: []string{"unknown b", "log c"},
+	}, {
+		name: "other namespace",
+		events: func(ctx context.Context, e *event.Exporter) {
+			e.SetPolicy("golang.org/x/exp/event", event.Policy{DisableLogging: true})
+			e.SetPolicy("golang.org/x/exp/event/adapter", event.Policy{DisableLogging: true})
+			event.Log(ctx, "a")
+		},
+		expect: []string{"log a"},
+	}, {
+		name: "longest match",
+		events: func(ctx context.Context, e *event.Exporter) {
+			e.SetPolicy("", event.Policy{DisableLogging: true})
+			e.SetPolicy("golang.org", event.Policy{DisableLogging: true})
+			e.SetPolicy("golang.org/x/exp", event.Policy{DisableAnnotations: true})
+			event.Log(ctx, "a")
+			event.Annotate(ctx, event.String("annotate", "b"))
+		},
+		expect: []string{"log a"},
+	}, {
+		name: "min level",
+		events: func(ctx context.Context, e *event.Exporter) {
+			e.SetPolicy(thisSpace, event.Policy{MinLevel: uint64(severity.Info)})
+			severity.Debug.Log(ctx, "a")
+			severity.Info.Log(ctx, "b")
+			severity.Warning.Logf(ctx, "c")
+			event.Log(ctx, "d")
+			event.Log(ctx, "e", event.Int64("level", int64(severity.Trace)))
+		},
+		expect: []string{"log b", "log c", "log d"},
+	}, {
+		name: "tracing",
+		events: func(ctx context.Context, e *event.Exporter) {
+			e.SetPolicy(thisSpace, event.Policy{DisableTracing: true})
+			ctx = event.Start(ctx, "a")
+			event.Log(ctx, "b")
+			e.RemovePolicy(thisSpace)
+			event.End(ctx)
+			ctx = event.Start(ctx, "c")
+			e.SetPolicy(thisSpace, event.Policy{DisableTracing: true})
+			event.End(ctx)
+		},
+		expect: []string{"log b", "start c", "end"},
+	}, {
+		name: "default tracing",
+		events: func(ctx context.Context, e *event.Exporter) {
+			e.SetPolicy("", event.Policy{DisableTracing: true})
+			ctx = event.Start(ctx, "a")
+			e.SetPolicy("", event.Policy{})
+			event.End(ctx)
+			ctx = event.Start(ctx, "b")
+			e.SetPolicy("", event.Policy{DisableTracing: true})
+			event.End(ctx)
+		},
+		expect: []string{"start b", "end"},
+	}, {
+		name: "metrics",
+		events: func(ctx context.Context, e *event.Exporter) {
+			e.SetPolicy("", event.Policy{DisableMetrics: true})
+			gauge.Record(ctx, 1)
+		},
+		expect: nil,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			h := &eventtest.CaptureHandler{}
+			test.opts.EnableNamespaces = true
+			e := event.NewExporter(h, &test.opts)
+			test.events(event.WithExporter(context.Background(), e), e)
+			var got []string
+			for _, ev := range h.Got {
+				got = append(got, summarize(ev))
+			}
+			if diff := cmp.Diff(test.expect, got); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// summarize returns the kind and text of an event.
+func summarize(ev event.Event) string {
+	s := ev.Kind.String()
+	for _, l := range ev.Labels {
+		switch l.Name {
+		case "msg", "name", "annotate":
+			s += " " + l.String()
+		}
+	}
+	return s
+}
+
+func TestPolicyLookup(t *testing.T) {
+	e := event.NewExporter(&eventtest.CaptureHandler{}, &event.ExporterOptions{DisableMetrics: true})
+	e.SetPolicy("a/b", event.Policy{MinLevel: 1})
+	e.SetPolicy("a/b/c", event.Policy{MinLevel: 2})
+	for _, test := range []struct {
+		namespace string
+		expect    event.Policy
+	}{
+		{"", event.Policy{DisableMetrics: true}},
+		{"a", event.Policy{DisableMetrics: true}},
+		{"a/b", event.Policy{MinLevel: 1}},
+		{"a/bc", event.Policy{DisableMetrics: true}},
+		{"a/b/cd", event.Policy{MinLevel: 1}},
+		{"a/b/c/d", event.Policy{MinLevel: 2}},
+	} {
+		if got := e.Policy(test.namespace); got != test.expect {
+			t.Errorf("Policy(%q) = %+v, want %+v", test.namespace, got, test.expect)
+		}
+	}
+	e.RemovePolicy("")
+	if got := e.Policy("a"); got != (event.Policy{}) {
+		t.Errorf("after RemovePolicy, got %+v, want zero Policy", got)
+	}
+}
+
+// spanChecker checks that every End event delivered matches a Start event
+// that was delivered before it.
+type spanChecker struct {
+	open   map[uint64]bool
+	errors []string
+}
+
+func (h *spanChecker) Event(ctx context.Context, ev *event.Event) context.Context {
+	switch ev.Kind {
+	case event.StartKind:
+		h.open[ev.ID] = true
+	case event.EndKind:
+		if !h.open[ev.Parent] {
+			h.errors = append(h.errors, fmt.Sprintf("end of span %d that was not started", ev.Parent))
+		}
+		delete(h.open, ev.Parent)
+	}
+	return ctx
+}
+
+func TestPolicyConcurrent(t *testing.T) {
+	h := &spanChecker{open: map[uint64]bool{}}
+	e := event.NewExporter(h, &event.ExporterOptions{EnableNamespaces: true})
+	ctx := event.WithExporter(context.Background(), e)
+	policies := []event.Policy{
+		{DisableTracing: true},
+		{DisableLogging: true, MinLevel: uint64(severity.Warning)},
+		{},
+		{DisableTracing: true, DisableAnnotations: true},
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if i%5 == 4 {
+				e.RemovePolicy(thisSpace)
+			} else {
+				e.SetPolicy(thisSpace, policies[i%len(policies)])
+			}
+			e.SetPolicy("", policies[(i+1)%len(policies)])
+		}
+	}()
+	var workers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := 0; j < 500; j++ {
+				ctx := event.Start(ctx, "span")
+				severity.Info.Log(ctx, "info")
+				severity.Warning.Log(ctx, "warning")
+				event.Annotate(ctx, event.Int64("j", int64(j)))
+				gauge.Record(ctx, float64(j))
+				event.End(ctx)
+			}
+		}()
+	}
+	workers.Wait()
+	close(done)
+	wg.Wait()
+	for _, err := range h.errors {
+		t.Error(err)
+	}
+	if len(h.open) != 0 {
+		t.Errorf("%d spans were not ended", len(h.open))
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package event
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A Sampler decides which events are created.
+//
+// If an exporter has a Sampler, it is consulted by New for every event except
+// metric and end events. Metric events are never sampled, so that aggregated
+// values stay correct. End events are delivered exactly when the matching
+// Start event was.
+// If Sample returns false, New returns nil and the event is never built.
+type Sampler interface {
+	Sample(ctx context.Context, info SampleInfo) bool
+}
+
+// SampleInfo describes an event that a Sampler is asked about.
+type SampleInfo struct {
+	Kind Kind
+	// Parent is the ID of the enclosing trace event, or 0 if there is none.
+	Parent uint64
+	// Unsampled is true if the enclosing trace was dropped by a Sampler or
+	// by the exporter's Policy.
+	Unsampled bool
+}
+
+// SamplerFunc is an adapter to allow the use of ordinary functions as
+// Samplers.
+type SamplerFunc func(ctx context.Context, info SampleInfo) bool
+
+func (f SamplerFunc) Sample(ctx context.Context, info SampleInfo) bool {
+	return f(ctx, info)
+}
+
+// Probability returns a Sampler that keeps each event with probability p.
+// A p of 0 or less drops every event, a p of 1 or more keeps every event.
+// It is normally wrapped with ParentBased so that the decision is made once
+// per trace.
+func Probability(p float64) Sampler {
+	switch {
+	case p <= 0:
+		return SamplerFunc(func(context.Context, SampleInfo) bool { return false })
+	case p >= 1:
+		return SamplerFunc(func(context.Context, SampleInfo) bool { return true })
+	}
+	return SamplerFunc(func(context.Context, SampleInfo) bool {
+		return rand.Float64() < p
+	})
+}
+
+// ParentBased returns a Sampler that uses root to decide about events that
+// are not inside a trace, and makes all other events follow the decision
+// made for the trace they belong to.
+func ParentBased(root Sampler) Sampler {
+	return SamplerFunc(func(ctx context.Context, info SampleInfo) bool {
+		switch {
+		case info.Unsampled:
+			return false
+		case info.Parent != 0:
+			return true
+		default:
+			return root.Sample(ctx, info)
+		}
+	})
+}
+
+// AllOf returns a Sampler that keeps an event only if every one of samplers
+// keeps it. The samplers are consulted in order, stopping at the first one
+// that drops the event.
+func AllOf(samplers ...Sampler) Sampler {
+	return SamplerFunc(func(ctx context.Context, info SampleInfo) bool {
+		for _, s := range samplers {
+			if !s.Sample(ctx, info) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// RateLimit returns a Sampler that keeps at most limits[k] events of kind k
+// per second, allowing bursts of up to one second's worth of events.
+// Kinds that are not in limits are not limited, and those with a limit of
+// zero or less are all dropped.
+// If now is nil, time.Now is used.
+func RateLimit(limits map[Kind]float64, now func() time.Time) Sampler {
+	if now == nil {
+		now = time.Now
+	}
+	r := &rateLimiter{now: now, buckets: make(map[Kind]*bucket, len(limits))}
+	for k, limit := range limits {
+		burst := limit
+		switch {
+		case limit <= 0:
+			limit, burst = 0, 0
+		case burst < 1:
+			burst = 1
+		}
+		r.buckets[k] = &bucket{rate: limit, burst: burst, tokens: burst}
+	}
+	return r
+}
+
+type rateLimiter struct {
+	now     func() time.Time
+	mu      sync.Mutex
+	buckets map[Kind]*bucket // never modified after construction
+}
+
+// bucket is a token bucket for a single kind of event.
+type bucket struct {
+	rate   float64 // tokens added per second
+	burst  float64 // maximum number of tokens
+	tokens float64
+	last   time.Time
+}
+
+func (r *rateLimiter) Sample(ctx context.Context, info SampleInfo) bool {
+	b, ok := r.buckets[info.Kind]
+	if !ok {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,203 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !disable_events
+
+package event_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/eventtest"
+)
+
+func TestSampler(t *testing.T) {
+	// dropMarked drops events whose context has been marked with dropKey.
+	dropMarked := event.SamplerFunc(func(ctx context.Context, info event.SampleInfo) bool {
+		return ctx.Value(dropKey{}) == nil
+	})
+	for _, test := range []struct {
+		name    string
+		sampler event.Sampler
+		events  func(context.Context)
+		expect  []event.Event
+	}{{
+		name:    "never",
+		sampler: event.Probability(0),
+		events: func(ctx context.Context) {
+			ctx = event.Start(ctx, "span")
+			event.Log(ctx, "message")
+			event.End(ctx)
+		},
+		expect: nil,
+	}, {
+		name:    "always",
+		sampler: event.Probability(1),
+		events: func(ctx context.Context) {
+			ctx = event.Start(ctx, "span")
+			event.Log(ctx, "message")
+			event.End(ctx)
+		},
+		expect: []event.Event{{
+			ID:     1,
+			Kind:   event.StartKind,
+			Labels: []event.Label{event.String("name", "span")},
+		}, {
+			ID:     2,
+			Parent: 1,
+			Kind:   event.LogKind,
+			Labels: []event.Label{event.String("msg", "message")},
+		}, {
+			ID:     3,
+			Parent: 1,
+			Kind:   event.EndKind,
+			Labels: []event.Label{},
+		}},
+	}, {
+		name:    "metrics are not sampled",
+		sampler: event.Probability(0),
+		events:  func(ctx context.Context) { counter.Record(ctx, 2) },
+		expect: []event.Event{{
+			ID:   1,
+			Kind: event.MetricKind,
+			Labels: []event.Label{
+				event.Int64("metricValue", 2),
+				event.Value("metric", counter),
+			},
+		}},
+	}, {
+		name:    "parent based drop",
+		sampler: event.ParentBased(dropMarked),
+		events: func(ctx context.Context) {
+			ctx = event.Start(context.WithValue(ctx, dropKey{}, true), "parent")
+			child := event.Start(context.WithValue(ctx, dropKey{}, nil), "child")
+			event.Log(child, "message")
+			event.End(child)
+			event.End(ctx)
+		},
+		expect: nil,
+	}, {
+		name:    "parent based keep",
+		sampler: event.ParentBased(dropMarked),
+		events: func(ctx context.Context) {
+			ctx = event.Start(ctx, "parent")
+			child := event.Start(context.WithValue(ctx, dropKey{}, true), "child")
+			event.End(child)
+			event.End(ctx)
+		},
+		expect: []event.Event{{
+			ID:     1,
+			Kind:   event.StartKind,
+			Labels: []event.Label{event.String("name", "parent")},
+		}, {
+			ID:     2,
+			Parent: 1,
+			Kind:   event.StartKind,
+			Labels: []event.Label{event.String("name", "child")},
+		}, {
+			ID:     3,
+			Parent: 2,
+			Kind:   event.EndKind,
+			Labels: []event.Label{},
+		}, {
+			ID:     4,
+			Parent: 1,
+			Kind:   event.EndKind,
+			Labels: []event.Label{},
+		}},
+	}, {
+		name:    "dropped child",
+		sampler: dropMarked,
+		events: func(ctx context.Context) {
+			ctx = event.Start(ctx, "parent")
+			child := event.Start(context.WithValue(ctx, dropKey{}, true), "child")
+			event.End(child)
+			event.End(ctx)
+		},
+		expect: []event.Event{{
+			ID:     1,
+			Kind:   event.StartKind,
+			Labels: []event.Label{event.String("name", "parent")},
+		}, {
+			ID:     2,
+			Parent: 1,
+			Kind:   event.EndKind,
+			Labels: []event.Label{},
+		}},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			h := &eventtest.CaptureHandler{}
+			opts := eventtest.ExporterOptions()
+			opts.Sampler = test.sampler
+			ctx := event.WithExporter(context.Background(), event.NewExporter(h, opts))
+			test.events(ctx)
+			if diff := cmp.Diff(test.expect, h.Got, eventtest.CmpOptions()...); diff != "" {
+				t.Errorf("mismatch (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+type dropKey struct{}
+
+func TestRateLimit(t *testing.T) {
+	now := eventtest.InitialTime
+	s := event.RateLimit(map[event.Kind]float64{event.LogKind: 2}, func() time.Time { return now })
+	h := &eventtest.CaptureHandler{}
+	opts := eventtest.ExporterOptions()
+	opts.Sampler = s
+	ctx := event.WithExporter(context.Background(), event.NewExporter(h, opts))
+	count := func() int {
+		n := 0
+		for _, ev := range h.Got {
+			if ev.Kind == event.LogKind {
+				n++
+			}
+		}
+		h.Reset()
+		return n
+	}
+	for i := 0; i < 5; i++ {
+		event.Log(ctx, "message")
+		event.Annotate(ctx, l1)
+	}
+	if got := count(); got != 2 {
+		t.Errorf("burst: got %d log events, want 2", got)
+	}
+	now = now.Add(500 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		event.Log(ctx, "message")
+	}
+	if got := count(); got != 1 {
+		t.Errorf("after 500ms: got %d log events, want 1", got)
+	}
+	now = now.Add(time.Hour)
+	for i := 0; i < 5; i++ {
+		event.Log(ctx, "message")
+	}
+	if got := count(); got != 2 {
+		t.Errorf("after an hour: got %d log events, want 2", got)
+	}
+}
+
+func TestRateLimitZero(t *testing.T) {
+	now := eventtest.InitialTime
+	s := event.RateLimit(map[event.Kind]float64{event.LogKind: 0, event.MetricKind: -1}, func() time.Time { return now })
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		for _, kind := range []event.Kind{event.LogKind, event.MetricKind} {
+			if s.Sample(ctx, event.SampleInfo{Kind: kind}) {
+				t.Errorf("after %d hours: kept a %v event", i, kind)
+			}
+		}
+		now = now.Add(time.Hour)
+	}
+	if !s.Sample(ctx, event.SampleInfo{Kind: event.StartKind}) {
+		t.Error("dropped an event of a kind with no limit")
+	}
+}
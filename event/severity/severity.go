@@ -40,6 +40,11 @@ const (
 
 const Key = "level"
 
+func init() {
+	// so that events logged through a Level get the source of its caller
+	event.RegisterHelper("golang.org/x/exp/event/severity")
+}
+
 // Of creates a label for the level.
 func (l Level) Label() event.Label {
 	return event.Value(Key, l)
@@ -11,6 +11,9 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 )
 
 const (
@@ -22,12 +25,6 @@ type sources struct {
 	entries []caller
 }
 
-type Source struct {
-	Space string
-	Owner string
-	Name  string
-}
-
 type caller struct {
 	helper bool
 	pc     uintptr
@@ -117,9 +114,14 @@ func (c *sources) scanStack() Source {
 	//   event.(*sources).scanStack (this function)
 	//   another function in this package (because scanStack is private)
 	depth := runtime.Callers(3, stack[:]) // start at 2 to skip Callers and this function
+	return c.scan(stack[:depth])
+}
+
+// scan returns the source of the first non helper entry of stack, adding any
+// entries it did not have yet.
+func (c *sources) scan(stack []uintptr) Source {
 	// do a cheap first pass to see if we have an entry for this stack
-	for i := 0; i < depth; i++ {
-		pc := stack[i]
+	for _, pc := range stack {
 		e, found := c.getCaller(pc)
 		if found {
 			if !e.helper {
@@ -130,7 +132,7 @@ func (c *sources) scanStack() Source {
 			continue
 		}
 		// stack entry not found, we need to fill one in
-		f := runtime.FuncForPC(stack[i])
+		f := runtime.FuncForPC(pc)
 		if f == nil {
 			// symtab lookup failed, pretend it does not exist
 			continue
@@ -150,6 +152,49 @@ func (c *sources) scanStack() Source {
 	return Source{}
 }
 
+// callerCache is a set of sources that can be scanned without locking.
+// It is copied on write, so only stacks with callers that were not seen
+// before take the lock.
+type callerCache struct {
+	mu      sync.Mutex     // serializes updates
+	current unsafe.Pointer // *sources, accessed using atomic
+}
+
+func newCallerCache() *callerCache {
+	c := newCallers()
+	return &callerCache{current: unsafe.Pointer(&c)}
+}
+
+func (c *callerCache) scanStack() Source {
+	var stack [helperDepthLimit]uintptr
+	// skip runtime.Callers, this function and its caller in this package
+	depth := runtime.Callers(3, stack[:])
+	s := (*sources)(atomic.LoadPointer(&c.current))
+	for _, pc := range stack[:depth] {
+		e, found := s.getCaller(pc)
+		if !found {
+			return c.add(stack[:depth])
+		}
+		if !e.helper {
+			return e.source
+		}
+	}
+	return Source{}
+}
+
+// add scans stack under the lock, and installs a copy of the sources with the
+// callers that were missing.
+func (c *callerCache) add(stack []uintptr) Source {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := (*sources)(atomic.LoadPointer(&c.current))
+	s := &sources{entries: make([]caller, len(old.entries), len(old.entries)+len(stack))}
+	copy(s.entries, old.entries)
+	source := s.scan(stack)
+	atomic.StorePointer(&c.current, unsafe.Pointer(s))
+	return source
+}
+
 // we do helper matching by name, if the pc matched we would have already found
 // that, but helper registration does not know the call stack pcs
 func (c *sources) isHelper(entry caller) bool {
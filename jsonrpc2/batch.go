@@ -0,0 +1,103 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"context"
+
+	"golang.org/x/exp/event"
+	errors "golang.org/x/xerrors"
+)
+
+// Batcher collects calls and notifications to send to the peer together, as
+// a single Batch message.
+// The peer may handle the requests of a batch in any order.
+type Batcher struct {
+	conn     *Connection
+	msgs     Batch
+	calls    []*AsyncCall
+	notifies []context.Context // the contexts of the notifications in msgs
+	sent     bool
+}
+
+// NewBatch returns an empty batch of requests for the connection.
+func (c *Connection) NewBatch() *Batcher {
+	return &Batcher{conn: c}
+}
+
+// Call adds a call to the batch, and returns an object that can be used to
+// await the response once the batch has been sent.
+// If the params cannot be marshaled, the call is not added to the batch, and
+// the response is ready with the error.
+func (b *Batcher) Call(ctx context.Context, method string, params interface{}) *AsyncCall {
+	result, call := b.conn.newCall(ctx, method, params)
+	b.calls = append(b.calls, result)
+	if call != nil {
+		b.msgs = append(b.msgs, call)
+	}
+	return result
+}
+
+// Notify adds a notification to the batch.
+func (b *Batcher) Notify(ctx context.Context, method string, params interface{}) error {
+	notify, err := NewNotification(method, params)
+	if err != nil {
+		return errors.Errorf("marshaling notify parameters: %v", err)
+	}
+	ctx = event.Start(ctx, method, RPCDirection(Outbound))
+	Started.Record(ctx, 1, Method(method))
+	b.msgs = append(b.msgs, notify)
+	b.notifies = append(b.notifies, ctx)
+	return nil
+}
+
+// Send sends the batch to the peer.
+// If sending fails, the responses of all the calls of the batch are ready
+// with the error.
+// A batch can only be sent once.
+func (b *Batcher) Send(ctx context.Context) error {
+	if b.sent {
+		return errors.New("jsonrpc2: batch already sent")
+	}
+	b.sent = true
+	var err error
+	if len(b.msgs) > 0 {
+		err = b.conn.write(ctx, b.msgs)
+	}
+	var errLabel event.Label
+	if err != nil {
+		errLabel = event.Value("error", err)
+		for _, msg := range b.msgs {
+			if call, ok := msg.(*Request); ok && call.IsCall() {
+				b.conn.failCall(call.ID, err)
+			}
+		}
+	}
+	for _, nctx := range b.notifies {
+		Finished.Record(nctx, 1, errLabel)
+		event.End(nctx)
+	}
+	return err
+}
+
+// Await waits for the responses to all the calls of the batch, which must have
+// been sent.
+// The result of each call is unmarshaled into the element of results at the
+// same position, in the order the calls were added; the result is discarded if
+// there is no such element or it is nil.
+// It returns the first error of any of the calls.
+func (b *Batcher) Await(ctx context.Context, results ...interface{}) error {
+	var first error
+	for i, call := range b.calls {
+		var result interface{}
+		if i < len(results) {
+			result = results[i]
+		}
+		if err := call.Await(ctx, result); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
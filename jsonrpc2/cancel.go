@@ -0,0 +1,159 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"golang.org/x/exp/event"
+	errors "golang.org/x/xerrors"
+)
+
+// CancelProtocol is an optional layer on top of JSON RPC 2 that lets a caller
+// cancel the calls it has made, and a handler report the progress of a call,
+// using notifications in the style of the Language Server Protocol.
+// It is enabled with ConnectionOptions.CancelProtocol.
+//
+// When the context of a call, or the context passed to Await, is done while
+// Await is waiting, a cancel notification for the call is sent to the peer.
+// When a cancel notification arrives, the call it names is cancelled with
+// Connection.Cancel.
+//
+// Progress notifications carry a token and a value. A caller receives the
+// progress of a call by making it with a context from WithProgress, and the
+// handler of the call reports progress with Connection.Progress, using the ID
+// of the call as the token.
+//
+// Both kinds of notification are handled as soon as they are read, before the
+// Preempter, so a progress notification is always delivered before the
+// response to its call. Progress notifications for unknown tokens are
+// delivered to the Preempter and Handler like other requests.
+type CancelProtocol struct {
+	// CancelMethod is the method of the notifications that cancel a call,
+	// whose params are {"id": <call id>}.
+	// If empty, "$/cancelRequest" is used.
+	CancelMethod string
+	// ProgressMethod is the method of the notifications that report the
+	// progress of a call, whose params are {"token": <token>, "value": <value>}.
+	// If empty, "$/progress" is used.
+	ProgressMethod string
+}
+
+// ProgressFunc is called with the value of each progress notification for a
+// call. It is called by the goroutine that reads the connection, so it must
+// not block.
+type ProgressFunc func(value json.RawMessage)
+
+type cancelParams struct {
+	ID interface{} `json:"id"`
+}
+
+type progressParams struct {
+	Token interface{}     `json:"token"`
+	Value json.RawMessage `json:"value"`
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context for calls whose progress notifications are
+// delivered to f, until their response arrives.
+// It has no effect on connections without a CancelProtocol.
+func WithProgress(ctx context.Context, f ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+// withDefaults returns a copy of p with the default methods filled in.
+func (p CancelProtocol) withDefaults() *CancelProtocol {
+	if p.CancelMethod == "" {
+		p.CancelMethod = "$/cancelRequest"
+	}
+	if p.ProgressMethod == "" {
+		p.ProgressMethod = "$/progress"
+	}
+	return &p
+}
+
+// Progress sends a progress notification for token, which is usually the ID of
+// the call being handled.
+// It fails if the connection has no CancelProtocol.
+func (c *Connection) Progress(ctx context.Context, token ID, value interface{}) error {
+	<-c.ready
+	if c.protocol == nil {
+		return errors.New("jsonrpc2: progress needs a CancelProtocol")
+	}
+	raw, err := marshalToRaw(value)
+	if err != nil {
+		return errors.Errorf("marshaling progress value: %v", err)
+	}
+	return c.Notify(ctx, c.protocol.ProgressMethod, &progressParams{Token: token.value, Value: raw})
+}
+
+// cancelRemote asks the peer to cancel the call, once.
+func (a *AsyncCall) cancelRemote() {
+	a.cancelOnce.Do(func() {
+		if a.conn == nil || a.conn.protocol == nil {
+			return
+		}
+		// the call context is done, but the notification should still go out
+		ctx := detach(a.ctx)
+		if err := a.conn.Notify(ctx, a.conn.protocol.CancelMethod, &cancelParams{ID: a.id.value}); err != nil {
+			event.Error(ctx, "jsonrpc2 cancel notification failed", err)
+		}
+	})
+}
+
+// intercept handles the notifications of the cancel protocol, and reports
+// whether req was one of them.
+func (c *Connection) intercept(ctx context.Context, req *Request) bool {
+	if c.protocol == nil || req.IsCall() {
+		return false
+	}
+	switch req.Method {
+	case c.protocol.CancelMethod:
+		var params cancelParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			event.Error(ctx, "jsonrpc2 invalid cancel notification", err)
+			return true
+		}
+		id, err := makeID(params.ID)
+		if err != nil {
+			event.Error(ctx, "jsonrpc2 invalid cancel notification", err)
+			return true
+		}
+		c.Cancel(id)
+		return true
+	case c.protocol.ProgressMethod:
+		var params progressParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return false
+		}
+		token, err := makeID(params.Token)
+		if err != nil {
+			return false
+		}
+		progress := <-c.progressBox
+		f := progress[token]
+		c.progressBox <- progress
+		if f == nil {
+			return false
+		}
+		f(params.Value)
+		return true
+	default:
+		return false
+	}
+}
+
+// detach returns a context that keeps the values of ctx, but is never done.
+func detach(ctx context.Context) context.Context { return detachedContext{ctx} }
+
+type detachedContext struct{ parent context.Context }
+
+func (v detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (v detachedContext) Done() <-chan struct{}             { return nil }
+func (v detachedContext) Err() error                        { return nil }
+func (v detachedContext) Value(key interface{}) interface{} { return v.parent.Value(key) }
@@ -0,0 +1,206 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// progressServer binds connections whose handler reports progress for
+// "count", and blocks "block" until it is cancelled.
+type progressServer struct {
+	protocol *jsonrpc2.CancelProtocol
+	started  chan struct{} // "block" has started
+	done     chan error    // "block" has finished, with its error
+}
+
+func (s *progressServer) Bind(ctx context.Context, conn *jsonrpc2.Connection) (jsonrpc2.ConnectionOptions, error) {
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+		switch req.Method {
+		case "count":
+			var n int
+			if err := json.Unmarshal(req.Params, &n); err != nil {
+				return nil, err
+			}
+			for i := 1; i <= n; i++ {
+				if err := conn.Progress(ctx, req.ID, i); err != nil {
+					return nil, err
+				}
+			}
+			return n, nil
+		case "block":
+			s.started <- struct{}{}
+			select {
+			case <-ctx.Done():
+				s.done <- ctx.Err()
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				s.done <- nil
+				return nil, errors.New("never cancelled")
+			}
+		case "echo":
+			return req.Params, nil
+		default:
+			return nil, jsonrpc2.ErrNotHandled
+		}
+	})
+	return jsonrpc2.ConnectionOptions{
+		Handler:        handler,
+		CancelProtocol: s.protocol,
+		Concurrency:    4,
+		Parallel:       func(*jsonrpc2.Request) bool { return true },
+	}, nil
+}
+
+func startProgress(t *testing.T, ctx context.Context, protocol *jsonrpc2.CancelProtocol) (*progressServer, *jsonrpc2.Connection, func()) {
+	s := &progressServer{
+		protocol: protocol,
+		started:  make(chan struct{}, 1),
+		done:     make(chan error, 1),
+	}
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{CancelProtocol: protocol})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, client, func() {
+		listener.Close()
+		client.Close()
+		server.Wait()
+	}
+}
+
+var protocols = []struct {
+	name     string
+	protocol *jsonrpc2.CancelProtocol
+}{
+	{"default", &jsonrpc2.CancelProtocol{}},
+	{"custom", &jsonrpc2.CancelProtocol{CancelMethod: "stop", ProgressMethod: "status"}},
+}
+
+func TestCancelAwait(t *testing.T) {
+	for _, test := range protocols {
+		t.Run(test.name, func(t *testing.T) {
+			stacktest.NoLeak(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			s, client, shutdown := startProgress(t, ctx, test.protocol)
+			defer shutdown()
+
+			call := client.Call(ctx, "block", nil)
+			<-s.started
+			actx, acancel := context.WithCancel(ctx)
+			acancel()
+			if err := call.Await(actx, nil); err != context.Canceled {
+				t.Fatalf("Await with a cancelled context returned %v", err)
+			}
+			if err := <-s.done; err != context.Canceled {
+				t.Fatalf("the handler finished with %v, want it cancelled", err)
+			}
+			if err := call.Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrRequestCancelled) {
+				t.Fatalf("got %v, want ErrRequestCancelled", err)
+			}
+		})
+	}
+}
+
+func TestCancelCall(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s, client, shutdown := startProgress(t, ctx, &jsonrpc2.CancelProtocol{})
+	defer shutdown()
+
+	cctx, ccancel := context.WithCancel(ctx)
+	call := client.Call(cctx, "block", nil)
+	<-s.started
+	ccancel()
+	// the context of the call is done, so Await does not wait for the response
+	if err := call.Await(ctx, nil); err != context.Canceled {
+		t.Fatalf("Await of a cancelled call returned %v", err)
+	}
+	if err := <-s.done; err != context.Canceled {
+		t.Fatalf("the handler finished with %v, want it cancelled", err)
+	}
+}
+
+func TestCancelRace(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, client, shutdown := startProgress(t, ctx, &jsonrpc2.CancelProtocol{})
+	defer shutdown()
+
+	for i := 0; i < 100; i++ {
+		cctx, ccancel := context.WithCancel(ctx)
+		call := client.Call(cctx, "echo", i)
+		go ccancel()
+		var got int
+		switch err := call.Await(ctx, &got); {
+		case err == context.Canceled:
+			// the cancel won; the response, if any, is still delivered
+		case err != nil:
+			t.Fatalf("call %d: %v", i, err)
+		case got != i:
+			t.Fatalf("call %d: got %d", i, got)
+		}
+	}
+	// the connection is still usable
+	var got string
+	if err := client.Call(ctx, "echo", "ok").Await(ctx, &got); err != nil || got != "ok" {
+		t.Fatalf("got %q, %v after the races", got, err)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	for _, test := range protocols {
+		t.Run(test.name, func(t *testing.T) {
+			stacktest.NoLeak(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, client, shutdown := startProgress(t, ctx, test.protocol)
+			defer shutdown()
+
+			var got []string
+			pctx := jsonrpc2.WithProgress(ctx, func(value json.RawMessage) {
+				got = append(got, string(value))
+			})
+			if err := client.Call(pctx, "count", 3).Await(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+			// all the progress arrives before the response
+			if fmt.Sprint(got) != "[1 2 3]" {
+				t.Errorf("got progress %v, want [1 2 3]", got)
+			}
+		})
+	}
+}
+
+func TestProgressWithoutProtocol(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, client, shutdown := startProgress(t, ctx, nil)
+	defer shutdown()
+
+	if err := client.Call(ctx, "count", 1).Await(ctx, nil); err == nil {
+		t.Fatal("progress without a CancelProtocol succeeded")
+	}
+}
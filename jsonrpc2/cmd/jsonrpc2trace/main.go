@@ -0,0 +1,216 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command jsonrpc2trace prints the traces recorded by jsonrpc2.TraceFramer.
+//
+// Usage:
+//
+//	jsonrpc2trace [flags] [file...]
+//
+// It reads the named trace files, or the standard input if there are none,
+// and prints one line per message with its time, direction, kind, ID and
+// method, followed by its params, result or error.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+var (
+	methodFlag = flag.String("method", "", "only show messages whose method matches this regular expression, with their responses")
+	dirFlag    = flag.String("dir", "", "only show messages in this direction (in or out)")
+	errorsFlag = flag.Bool("errors", false, "only show error responses")
+	indentFlag = flag.Bool("indent", false, "indent the JSON of each message")
+	rawFlag    = flag.Bool("raw", false, "print the matching trace entries as JSON lines")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("jsonrpc2trace: ")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: jsonrpc2trace [flags] [file...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	f, err := newFilter(*methodFlag, *dirFlag, *errorsFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var inputs []io.Reader
+	for _, name := range flag.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		inputs = append(inputs, file)
+	}
+	if len(inputs) == 0 {
+		inputs = append(inputs, os.Stdin)
+	}
+	for _, in := range inputs {
+		trace, err := jsonrpc2.ReadTrace(in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := printTrace(os.Stdout, f, trace, *indentFlag, *rawFlag); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// filter selects the entries of a trace.
+type filter struct {
+	methods *regexp.Regexp
+	dir     string
+	errors  bool
+	calls   map[string]string // the method of each call seen, by direction and ID
+}
+
+func newFilter(method, dir string, errors bool) (*filter, error) {
+	f := &filter{dir: dir, errors: errors, calls: make(map[string]string)}
+	switch dir {
+	case "", jsonrpc2.Inbound, jsonrpc2.Outbound:
+	default:
+		return nil, fmt.Errorf("invalid direction %q, want %q or %q", dir, jsonrpc2.Inbound, jsonrpc2.Outbound)
+	}
+	if method != "" {
+		re, err := regexp.Compile(method)
+		if err != nil {
+			return nil, err
+		}
+		f.methods = re
+	}
+	return f, nil
+}
+
+// message is the wire form of a message, with the fields the filter and
+// printer need.
+type message struct {
+	ID     interface{}     `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// messages decodes a message, or the messages of a batch.
+func messages(data json.RawMessage) ([]message, error) {
+	if d := bytes.TrimSpace(data); len(d) > 0 && d[0] == '[' {
+		var batch []message
+		err := json.Unmarshal(d, &batch)
+		return batch, err
+	}
+	var msg message
+	err := json.Unmarshal(data, &msg)
+	return []message{msg}, err
+}
+
+// callKey identifies a call by the direction it was sent in and its ID.
+func callKey(dir string, id interface{}) string {
+	return fmt.Sprintf("%s %v", dir, id)
+}
+
+// method returns the method of a message, which for a response is the method
+// of its call.
+func (f *filter) method(dir string, msg message) string {
+	if msg.Method != "" {
+		return msg.Method
+	}
+	// the call went the other way
+	other := jsonrpc2.Inbound
+	if dir == jsonrpc2.Inbound {
+		other = jsonrpc2.Outbound
+	}
+	return f.calls[callKey(other, msg.ID)]
+}
+
+// match reports whether a message should be shown.
+// It must be called for every message of the trace in order, so that it can
+// relate responses to their calls.
+func (f *filter) match(dir string, msg message) bool {
+	if msg.Method != "" && msg.ID != nil {
+		f.calls[callKey(dir, msg.ID)] = msg.Method
+	}
+	switch {
+	case f.dir != "" && dir != f.dir:
+		return false
+	case f.errors && msg.Error == nil:
+		return false
+	case f.methods != nil && !f.methods.MatchString(f.method(dir, msg)):
+		return false
+	}
+	return true
+}
+
+func printTrace(w io.Writer, f *filter, trace []jsonrpc2.TraceEntry, indent, raw bool) error {
+	enc := json.NewEncoder(w)
+	for i, entry := range trace {
+		msgs, err := messages(entry.Message)
+		if err != nil {
+			return fmt.Errorf("trace entry %d: %v", i, err)
+		}
+		// every message goes through match, which records the methods of calls
+		matched := false
+		var lines []string
+		for _, msg := range msgs {
+			if !f.match(entry.Direction, msg) {
+				continue
+			}
+			matched = true
+			if !raw {
+				lines = append(lines, format(entry, f.method(entry.Direction, msg), msg, indent))
+			}
+		}
+		if raw && matched {
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// format returns the line for a message.
+func format(entry jsonrpc2.TraceEntry, method string, msg message, indent bool) string {
+	kind, payload := "notify", msg.Params
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		kind = "call"
+	case msg.Error != nil:
+		kind, payload = "error", msg.Error
+	case msg.Method == "":
+		kind, payload = "result", msg.Result
+	}
+	id := ""
+	if msg.ID != nil {
+		id = fmt.Sprintf("#%v", msg.ID)
+	}
+	var buf bytes.Buffer
+	if len(payload) > 0 {
+		if indent {
+			json.Indent(&buf, payload, "\t", "  ")
+		} else {
+			json.Compact(&buf, payload)
+		}
+	}
+	line := fmt.Sprintf("%s %-3s %-6s %-4s %s %s", entry.Time.Format("15:04:05.000000"), entry.Direction, kind, id, method, buf.String())
+	return strings.TrimRight(line, " ")
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+const testTrace = `
+{"time":"2022-01-02T03:04:05.000006Z","direction":"out","message":{"jsonrpc":"2.0","id":1,"method":"echo","params":{"a":[1, 2]}}}
+{"time":"2022-01-02T03:04:05.1Z","direction":"in","message":{"jsonrpc":"2.0","id":1,"result":"hello"}}
+{"time":"2022-01-02T03:04:05.2Z","direction":"out","message":{"jsonrpc":"2.0","method":"note"}}
+{"time":"2022-01-02T03:04:05.3Z","direction":"in","message":{"jsonrpc":"2.0","id":7,"method":"echo"}}
+{"time":"2022-01-02T03:04:05.4Z","direction":"out","message":[{"jsonrpc":"2.0","id":2,"method":"fail"},{"jsonrpc":"2.0","id":7,"result":null}]}
+{"time":"2022-01-02T03:04:05.5Z","direction":"in","message":[{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"nope"}}]}
+`
+
+func TestPrint(t *testing.T) {
+	trace, err := jsonrpc2.ReadTrace(strings.NewReader(testTrace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name   string
+		method string
+		dir    string
+		errors bool
+		want   string
+	}{
+		{"all", "", "", false, `
+03:04:05.000006 out call   #1   echo {"a":[1,2]}
+03:04:05.100000 in  result #1   echo "hello"
+03:04:05.200000 out notify      note
+03:04:05.300000 in  call   #7   echo
+03:04:05.400000 out call   #2   fail
+03:04:05.400000 out result #7   echo null
+03:04:05.500000 in  error  #2   fail {"code":-32601,"message":"nope"}
+`},
+		{"method", "^echo$", "", false, `
+03:04:05.000006 out call   #1   echo {"a":[1,2]}
+03:04:05.100000 in  result #1   echo "hello"
+03:04:05.300000 in  call   #7   echo
+03:04:05.400000 out result #7   echo null
+`},
+		{"direction", "", "in", false, `
+03:04:05.100000 in  result #1   echo "hello"
+03:04:05.300000 in  call   #7   echo
+03:04:05.500000 in  error  #2   fail {"code":-32601,"message":"nope"}
+`},
+		{"errors", "", "", true, `
+03:04:05.500000 in  error  #2   fail {"code":-32601,"message":"nope"}
+`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := newFilter(test.method, test.dir, test.errors)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := printTrace(&buf, f, trace, false, false); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := buf.String(), strings.TrimPrefix(test.want, "\n"); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestPrintRaw checks that the calls of a batch are all recorded, even after
+// one of them is printed.
+func TestPrintRaw(t *testing.T) {
+	const rawTrace = `{"time":"2022-01-02T03:04:05Z","direction":"out","message":[{"jsonrpc":"2.0","id":1,"method":"echo"},{"jsonrpc":"2.0","id":2,"method":"echo"}]}
+{"time":"2022-01-02T03:04:05.1Z","direction":"in","message":{"jsonrpc":"2.0","id":2,"result":"hello"}}
+{"time":"2022-01-02T03:04:05.2Z","direction":"in","message":{"jsonrpc":"2.0","method":"note"}}
+`
+	trace, err := jsonrpc2.ReadTrace(strings.NewReader(rawTrace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := newFilter("^echo$", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := printTrace(&buf, f, trace, false, true); err != nil {
+		t.Fatal(err)
+	}
+	got, err := jsonrpc2.ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[1].Time.Equal(trace[1].Time) {
+		t.Errorf("got %d entries, want the batch and its response:\n%v", len(got), got)
+	}
+}
+
+func TestBadFilter(t *testing.T) {
+	if _, err := newFilter("(", "", false); err == nil {
+		t.Error("an invalid method pattern was accepted")
+	}
+	if _, err := newFilter("", "sideways", false); err == nil {
+		t.Error("an invalid direction was accepted")
+	}
+}
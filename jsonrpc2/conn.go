@@ -9,7 +9,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/exp/event"
 	errors "golang.org/x/xerrors"
@@ -36,6 +38,72 @@ type ConnectionOptions struct {
 	// Handler is used as the queued message handler for inbound messages.
 	// If nil, all responses will be ErrNotHandled.
 	Handler Handler
+	// Concurrency is the maximum number of requests the Handler may handle at
+	// the same time. If it is less than 2, requests are handled one at a time,
+	// in the order they arrive.
+	Concurrency int
+	// Parallel reports whether a request may be handled concurrently with
+	// others, which only happens if Concurrency allows it.
+	// Requests for which it returns false, and all requests if it is nil, are
+	// ordered: an ordered request is only handled once all the requests that
+	// arrived before it have been handled, and no later request is handled
+	// until it has been.
+	// See ParallelMethods for a simple policy.
+	Parallel func(*Request) bool
+	// MaxQueued and MaxQueuedBytes limit the number and the total size of the
+	// requests waiting for the Handler, where the size of a request is the
+	// length of its method and params. Zero means no limit.
+	// A single request larger than MaxQueuedBytes is still queued if the queue
+	// is empty.
+	MaxQueued      int
+	MaxQueuedBytes int64
+	// Overload says what to do with requests that arrive when the queue is
+	// full.
+	Overload OverloadPolicy
+	// CancelProtocol, if not nil, enables the notifications that cancel calls
+	// and report their progress.
+	CancelProtocol *CancelProtocol
+	// Keepalive, if not nil, makes the connection ping the peer, and close
+	// when the peer stops responding.
+	Keepalive *Keepalive
+	// CallTimeout is how long Await waits for a response when its context has
+	// no deadline. Zero means no limit.
+	CallTimeout time.Duration
+}
+
+// OverloadPolicy says what a Connection does when its incoming queue is full.
+type OverloadPolicy int
+
+const (
+	// Backpressure holds back requests that arrive when the queue is full,
+	// without passing them to the Preempter, until there is room in the queue.
+	// The stream is still read, so that responses to the calls of the
+	// connection are delivered while the handler is busy, until the requests
+	// held back reach the limits of the queue; then the connection stops
+	// reading until there is room again.
+	Backpressure OverloadPolicy = iota
+	// RejectOverload answers calls that do not fit in the queue with
+	// ErrServerOverloaded. Notifications cannot be answered, so they are
+	// queued anyway, and later requests are held back as with Backpressure
+	// until the queue is back within its limits.
+	RejectOverload
+)
+
+// ParallelMethods returns a function for ConnectionOptions.Parallel that
+// allows requests for the given methods to be handled in parallel, and orders
+// all others.
+func ParallelMethods(methods ...string) func(*Request) bool {
+	return methodSet(methods)
+}
+
+// methodSet returns a function that reports whether a request is for one of
+// the methods.
+func methodSet(methods []string) func(*Request) bool {
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+	return func(req *Request) bool { return set[req.Method] }
 }
 
 // Connection manages the jsonrpc2 protocol, connecting responses back to their
@@ -48,7 +116,17 @@ type Connection struct {
 	writerBox   chan Writer
 	outgoingBox chan map[ID]chan<- *Response
 	incomingBox chan map[ID]*incoming
+	progressBox chan map[ID]ProgressFunc
+	lastRead    int64 // UnixNano of the last read for keepalive, must only be accessed using atomic operations
+	active      int64 // the number of incoming requests not yet finished, must only be accessed using atomic operations
+	draining    int32 // set to 1 by Shutdown, must only be accessed using atomic operations
 	async       async
+
+	// the following are set from the options once ready is closed
+	ready       chan struct{}
+	protocol    *CancelProtocol
+	keepalive   *Keepalive
+	callTimeout time.Duration
 }
 
 type AsyncCall struct {
@@ -56,6 +134,9 @@ type AsyncCall struct {
 	response  chan *Response // the channel a response will be delivered on
 	resultBox chan asyncResult
 	ctx       context.Context
+
+	conn       *Connection
+	cancelOnce sync.Once
 }
 
 type asyncResult struct {
@@ -69,6 +150,19 @@ type incoming struct {
 	baseCtx   context.Context // a base context for the message processing
 	handleCtx context.Context // the context for handling the message, child of baseCtx
 	cancel    func()          // a function that cancels the handling context
+	cancelled bool            // whether the request was cancelled by Cancel
+	batch     *incomingBatch  // the batch the request arrived in, if any
+	size      int64           // the size of the request, for MaxQueuedBytes
+	late      bool            // whether the request was read after Shutdown began
+}
+
+// incomingBatch collects the responses to the calls of an incoming batch, so
+// that they can be sent back together.
+type incomingBatch struct {
+	calls int // the number of calls in the batch, set before any are queued
+
+	mu        sync.Mutex
+	responses Batch
 }
 
 // Bind returns the options unmodified.
@@ -84,6 +178,8 @@ func newConnection(ctx context.Context, rwc io.ReadWriteCloser, binder Binder) (
 		writerBox:   make(chan Writer, 1),
 		outgoingBox: make(chan map[ID]chan<- *Response, 1),
 		incomingBox: make(chan map[ID]*incoming, 1),
+		progressBox: make(chan map[ID]ProgressFunc, 1),
+		ready:       make(chan struct{}),
 	}
 	c.async.init()
 
@@ -100,18 +196,32 @@ func newConnection(ctx context.Context, rwc io.ReadWriteCloser, binder Binder) (
 	if options.Handler == nil {
 		options.Handler = defaultHandler{}
 	}
+	if options.CancelProtocol != nil {
+		c.protocol = options.CancelProtocol.withDefaults()
+	}
+	if options.Keepalive != nil {
+		c.keepalive = options.Keepalive.withDefaults()
+		options.Preempter = keepalivePreempter{method: c.keepalive.Method, next: options.Preempter}
+	}
+	c.callTimeout = options.CallTimeout
+	// requests started by the binder may be waiting for the options
+	close(c.ready)
 	c.outgoingBox <- make(map[ID]chan<- *Response)
 	c.incomingBox <- make(map[ID]*incoming)
+	c.progressBox <- make(map[ID]ProgressFunc)
 	// the goroutines started here will continue until the underlying stream is closed
 	reader := options.Framer.Reader(rwc)
 	readToQueue := make(chan *incoming)
 	queueToDeliver := make(chan *incoming)
 	go c.readIncoming(ctx, reader, readToQueue)
-	go c.manageQueue(ctx, options.Preempter, readToQueue, queueToDeliver)
-	go c.deliverMessages(ctx, options.Handler, queueToDeliver)
+	go c.manageQueue(ctx, options, readToQueue, queueToDeliver)
+	go c.deliverMessages(ctx, options, queueToDeliver)
 	// releaseing the writer must be the last thing we do in case any requests
 	// are blocked waiting for the connection to be ready
 	c.writerBox <- options.Framer.Writer(rwc)
+	if c.keepalive != nil {
+		go c.runKeepalive(ctx)
+	}
 	return c, nil
 }
 
@@ -140,9 +250,25 @@ func (c *Connection) Notify(ctx context.Context, method string, params interface
 // You do not have to wait for the response, it can just be ignored if not needed.
 // If sending the call failed, the response will be ready and have the error in it.
 func (c *Connection) Call(ctx context.Context, method string, params interface{}) *AsyncCall {
+	result, call := c.newCall(ctx, method, params)
+	if call == nil {
+		return result
+	}
+	// now we are ready to send
+	if err := c.write(result.ctx, call); err != nil {
+		c.failCall(result.id, err)
+	}
+	return result
+}
+
+// newCall builds a call and registers it to receive its response.
+// If the params cannot be marshaled, the returned request is nil and the
+// result is ready with the error.
+func (c *Connection) newCall(ctx context.Context, method string, params interface{}) (*AsyncCall, *Request) {
 	result := &AsyncCall{
 		id:        Int64ID(atomic.AddInt64(&c.seq, 1)),
 		resultBox: make(chan asyncResult, 1),
+		conn:      c,
 	}
 	// TODO: rewrite this using the new target/prototype stuff
 	ctx = event.Start(ctx, method,
@@ -154,22 +280,35 @@ func (c *Connection) Call(ctx context.Context, method string, params interface{}
 	if err != nil {
 		// set the result to failed
 		result.resultBox <- asyncResult{err: errors.Errorf("marshaling call parameters: %w", err)}
-		return result
+		return result, nil
 	}
 	// We have to add ourselves to the pending map before we send, otherwise we
 	// are racing the response.
 	// rchan is buffered in case the response arrives without a listener.
 	result.response = make(chan *Response, 1)
 	pending := <-c.outgoingBox
+	if pending == nil {
+		// the connection can no longer read responses
+		c.outgoingBox <- pending
+		result.resultBox <- asyncResult{err: c.closedError()}
+		return result, nil
+	}
 	pending[result.id] = result.response
 	c.outgoingBox <- pending
-	// now we are ready to send
-	if err := c.write(ctx, call); err != nil {
-		// sending failed, we will never get a response, so deliver a fake one
-		r, _ := NewResponse(result.id, nil, err)
-		c.incomingResponse(r)
+	<-c.ready
+	if f, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && f != nil && c.protocol != nil {
+		progress := <-c.progressBox
+		progress[result.id] = f
+		c.progressBox <- progress
 	}
-	return result
+	return result, call
+}
+
+// failCall is used when sending a call failed, we will never get a response,
+// so it delivers a fake one.
+func (c *Connection) failCall(id ID, err error) {
+	r, _ := NewResponse(id, nil, err)
+	c.incomingResponse(r)
 }
 
 // ID used for this call.
@@ -191,9 +330,25 @@ func (a *AsyncCall) IsReady() bool {
 
 // Await the results of a Call.
 // The response will be unmarshaled from JSON into the result.
+// With a CancelProtocol, Await also returns when the context of the call is
+// done, and in either case asks the peer to cancel the call.
+// If ctx has no deadline, Await waits for at most the CallTimeout of the
+// connection.
 func (a *AsyncCall) Await(ctx context.Context, result interface{}) error {
 	status := "NONE"
 	defer event.End(a.ctx, StatusCode(status))
+	var callDone <-chan struct{}
+	if a.conn != nil {
+		<-a.conn.ready
+		if _, ok := ctx.Deadline(); !ok && a.conn.callTimeout > 0 {
+			var cancel func()
+			ctx, cancel = context.WithTimeout(ctx, a.conn.callTimeout)
+			defer cancel()
+		}
+		if a.conn.protocol != nil {
+			callDone = a.ctx.Done()
+		}
+	}
 	var r asyncResult
 	select {
 	case response := <-a.response:
@@ -210,7 +365,12 @@ func (a *AsyncCall) Await(ctx context.Context, result interface{}) error {
 		// result already available
 	case <-ctx.Done():
 		status = "CANCELLED"
+		a.cancelRemote()
 		return ctx.Err()
+	case <-callDone:
+		status = "CANCELLED"
+		a.cancelRemote()
+		return a.ctx.Err()
 	}
 	// refill the box for the next caller
 	a.resultBox <- r
@@ -252,6 +412,7 @@ func (c *Connection) Cancel(id ID) {
 	if entry, found := pending[id]; found && entry.cancel != nil {
 		entry.cancel()
 		entry.cancel = nil
+		entry.cancelled = true
 	}
 }
 
@@ -283,44 +444,150 @@ func (c *Connection) readIncoming(ctx context.Context, reader Reader, toQueue ch
 		// get the next message
 		// no lock is needed, this is the only reader
 		msg, n, err := reader.Read(ctx)
+		var berr *batchError
+		if errors.As(err, &berr) {
+			// the batch was read, but it needs error responses
+			c.touch()
+			ReceivedBytes.Record(ctx, n)
+			c.readBatch(ctx, berr.batch, berr, toQueue)
+			continue
+		}
 		if err != nil {
 			// The stream failed, we cannot continue
 			c.async.setError(err)
+			c.failOutgoing()
 			return
 		}
+		c.touch()
 		switch msg := msg.(type) {
 		case *Request:
-			entry := &incoming{
-				request: msg,
+			if c.intercept(ctx, msg) {
+				ReceivedBytes.Record(ctx, n, Method(msg.Method))
+				continue
 			}
-			// add a span to the context for this request
-			var idLabel event.Label
-			if msg.IsCall() {
-				idLabel = RPCID(fmt.Sprintf("%q", msg.ID))
-			}
-			entry.baseCtx = event.Start(ctx, msg.Method,
-				Method(msg.Method), RPCDirection(Inbound), idLabel)
-			Started.Record(entry.baseCtx, 1, Method(msg.Method))
+			entry := c.newIncoming(ctx, msg, nil)
 			ReceivedBytes.Record(entry.baseCtx, n, Method(msg.Method))
-			// in theory notifications cannot be cancelled, but we build them a cancel context anyway
-			entry.handleCtx, entry.cancel = context.WithCancel(entry.baseCtx)
-			// if the request is a call, add it to the incoming map so it can be
-			// cancelled by id
-			if msg.IsCall() {
-				pending := <-c.incomingBox
-				pending[msg.ID] = entry
-				c.incomingBox <- pending
-			}
 			// send the message to the incoming queue
 			toQueue <- entry
 		case *Response:
 			// If method is not set, this should be a response, in which case we must
 			// have an id to send the response back to the caller.
 			c.incomingResponse(msg)
+		case Batch:
+			ReceivedBytes.Record(ctx, n)
+			c.readBatch(ctx, msg, nil, toQueue)
 		}
 	}
 }
 
+// readBatch feeds the requests of an incoming batch to the queue, and
+// delivers its responses.
+// If the batch had invalid members, berr holds their errors, which are
+// answered along with the calls of the batch.
+func (c *Connection) readBatch(ctx context.Context, msg Batch, berr *batchError, toQueue chan<- *incoming) {
+	if berr != nil && berr.empty {
+		// an empty batch gets a single response rather than an empty array
+		if err := c.write(ctx, &Response{Error: berr.invalid[0]}); err != nil {
+			event.Error(ctx, "jsonrpc2 message delivery failed", err)
+		}
+		return
+	}
+	// count the calls first, so the batch cannot look complete before
+	// they have all been queued
+	batch := &incomingBatch{}
+	if berr != nil {
+		for _, err := range berr.invalid {
+			batch.responses = append(batch.responses, &Response{Error: err})
+		}
+		batch.calls = len(batch.responses)
+	}
+	invalid := batch.calls
+	for _, m := range msg {
+		if req, ok := m.(*Request); ok && req.IsCall() {
+			batch.calls++
+		}
+	}
+	for _, m := range msg {
+		switch m := m.(type) {
+		case *Request:
+			if !c.intercept(ctx, m) {
+				toQueue <- c.newIncoming(ctx, m, batch)
+			}
+		case *Response:
+			c.incomingResponse(m)
+		}
+	}
+	if invalid > 0 && invalid == batch.calls {
+		// every call was invalid, so nothing else will send the responses
+		if err := c.write(ctx, batch.responses); err != nil {
+			event.Error(ctx, "jsonrpc2 message delivery failed", err)
+		}
+	}
+}
+
+// newIncoming starts tracking an incoming request, which may be part of a
+// batch.
+func (c *Connection) newIncoming(ctx context.Context, msg *Request, batch *incomingBatch) *incoming {
+	atomic.AddInt64(&c.active, 1)
+	entry := &incoming{
+		request: msg,
+		batch:   batch,
+		size:    int64(len(msg.Method) + len(msg.Params)),
+		late:    atomic.LoadInt32(&c.draining) != 0,
+	}
+	// add a span to the context for this request
+	var idLabel event.Label
+	if msg.IsCall() {
+		idLabel = RPCID(fmt.Sprintf("%q", msg.ID))
+	}
+	entry.baseCtx = event.Start(ctx, msg.Method,
+		Method(msg.Method), RPCDirection(Inbound), idLabel)
+	Started.Record(entry.baseCtx, 1, Method(msg.Method))
+	// in theory notifications cannot be cancelled, but we build them a cancel context anyway
+	entry.handleCtx, entry.cancel = context.WithCancel(entry.baseCtx)
+	// if the request is a call, add it to the incoming map so it can be
+	// cancelled by id
+	if msg.IsCall() {
+		pending := <-c.incomingBox
+		pending[msg.ID] = entry
+		c.incomingBox <- pending
+	}
+	return entry
+}
+
+// failOutgoing fails the calls that are waiting for a response, and any later
+// calls, once responses can no longer be read.
+func (c *Connection) failOutgoing() {
+	err := c.closedError()
+	pending := <-c.outgoingBox
+	c.outgoingBox <- nil
+	for id, response := range pending {
+		r, _ := NewResponse(id, nil, err)
+		response <- r
+	}
+	<-c.progressBox
+	c.progressBox <- make(map[ID]ProgressFunc)
+}
+
+// closedError returns the error for calls that cannot get a response because
+// the connection is closed.
+func (c *Connection) closedError() error {
+	err := c.async.err()
+	if err == nil || isClosingError(err) {
+		err = io.ErrClosedPipe
+	}
+	return &connClosedError{err: err}
+}
+
+// connClosedError is the error of calls that lost their connection, wrapping
+// the reason the connection closed.
+type connClosedError struct {
+	err error
+}
+
+func (e *connClosedError) Error() string { return "jsonrpc2: connection closed: " + e.err.Error() }
+func (e *connClosedError) Unwrap() error { return e.err }
+
 func (c *Connection) incomingResponse(msg *Response) {
 	pending := <-c.outgoingBox
 	response, ok := pending[msg.ID]
@@ -328,6 +595,9 @@ func (c *Connection) incomingResponse(msg *Response) {
 		delete(pending, msg.ID)
 	}
 	c.outgoingBox <- pending
+	progress := <-c.progressBox
+	delete(progress, msg.ID)
+	c.progressBox <- progress
 	if response != nil {
 		response <- msg
 	}
@@ -335,81 +605,211 @@ func (c *Connection) incomingResponse(msg *Response) {
 
 // manageQueue reads incoming requests, attempts to process them with the preempter, or queue them
 // up for normal handling.
-func (c *Connection) manageQueue(ctx context.Context, preempter Preempter, fromRead <-chan *incoming, toDeliver chan<- *incoming) {
+func (c *Connection) manageQueue(ctx context.Context, options ConnectionOptions, fromRead <-chan *incoming, toDeliver chan<- *incoming) {
 	defer close(toDeliver)
-	q := []*incoming{}
-	ok := true
-	for {
-		var nextReq *incoming
-		if len(q) == 0 {
-			// no messages in the queue
-			// if we were closing, then we are done
-			if !ok {
-				return
-			}
-			// not closing, but nothing in the queue, so just block waiting for a read
-			nextReq, ok = <-fromRead
-		} else {
-			// we have a non empty queue, so pick whichever of reading or delivering
-			// that we can make progress on
-			select {
-			case nextReq, ok = <-fromRead:
-			case toDeliver <- q[0]:
-				// TODO: this causes a lot of shuffling, should we use a growing ring buffer? compaction?
-				q = q[1:]
-			}
-		}
-		if nextReq != nil {
-			// TODO: should we allow to limit the queue size?
-			var result interface{}
-			rerr := nextReq.handleCtx.Err()
-			if rerr == nil {
-				// only preempt if not already cancelled
-				result, rerr = preempter.Preempt(nextReq.handleCtx, nextReq.request)
-			}
-			switch {
-			case rerr == ErrNotHandled:
-				// message not handled, add it to the queue for the main handler
-				q = append(q, nextReq)
-			case rerr == ErrAsyncResponse:
-				// message handled but the response will come later
-			default:
-				// anything else means the message is fully handled
-				c.reply(nextReq, result, rerr)
-			}
+	// the replies made here are written by a goroutine of their own, so that
+	// the queue never waits for the peer to read, which may itself be waiting
+	// for us to read
+	toReply := make(chan *queuedReply)
+	replied := make(chan struct{})
+	go func() {
+		defer close(replied)
+		for r := range toReply {
+			c.reply(r.entry, r.result, r.err)
 		}
+	}()
+	defer func() {
+		close(toReply)
+		<-replied
+	}()
+	q := []*incoming{}
+	var qBytes int64 // the total size of the requests in q
+	// limited reports whether n requests of the given total size reach the
+	// limits of the queue.
+	limited := func(n int, size int64) bool {
+		return (options.MaxQueued > 0 && n >= options.MaxQueued) ||
+			(options.MaxQueuedBytes > 0 && size >= options.MaxQueuedBytes)
 	}
-}
-
-func (c *Connection) deliverMessages(ctx context.Context, handler Handler, fromQueue <-chan *incoming) {
-	defer c.async.done()
-	for entry := range fromQueue {
-		// cancel any messages in the queue that we have a pending cancel for
+	// full reports whether there is no room left in the queue, fits whether
+	// entry can be added without going beyond the limits, and over whether the
+	// queue is already beyond them.
+	full := func() bool {
+		return limited(len(q), qBytes)
+	}
+	fits := func(entry *incoming) bool {
+		return len(q) == 0 ||
+			((options.MaxQueued <= 0 || len(q) < options.MaxQueued) &&
+				(options.MaxQueuedBytes <= 0 || qBytes+entry.size <= options.MaxQueuedBytes))
+	}
+	over := func() bool {
+		return (options.MaxQueued > 0 && len(q) > options.MaxQueued) ||
+			(options.MaxQueuedBytes > 0 && qBytes > options.MaxQueuedBytes)
+	}
+	// throttled reports whether requests must wait before they are admitted
+	throttled := func() bool {
+		return over() || (options.Overload == Backpressure && full())
+	}
+	// replies holds the replies waiting to be written, in order
+	var replies []*queuedReply
+	var repliesBytes int64
+	addReply := func(entry *incoming, result interface{}, err error) {
+		replies = append(replies, &queuedReply{entry: entry, result: result, err: err})
+		repliesBytes += entry.size
+	}
+	// admit offers a request to the preempter, and queues it for the handler
+	// if the preempter leaves it
+	admit := func(entry *incoming) {
+		if entry.request.IsCall() && entry.late {
+			// the server is shutting down, only calls it already had are handled
+			addReply(entry, nil, errors.Errorf("%w: server shutting down", ErrServerOverloaded))
+			return
+		}
 		var result interface{}
 		rerr := entry.handleCtx.Err()
 		if rerr == nil {
-			// only deliver if not already cancelled
-			result, rerr = handler.Handle(entry.handleCtx, entry.request)
+			// only preempt if not already cancelled
+			result, rerr = options.Preempter.Preempt(entry.handleCtx, entry.request)
 		}
 		switch {
+		case rerr == ErrNotHandled && entry.request.IsCall() && options.Overload == RejectOverload && !fits(entry):
+			// no room for the message, and the caller can be told
+			addReply(entry, nil, ErrServerOverloaded)
 		case rerr == ErrNotHandled:
-			// message not handled, report it back to the caller as an error
-			c.reply(entry, nil, errors.Errorf("%w: %q", ErrMethodNotFound, entry.request.Method))
+			// message not handled, add it to the queue for the main handler
+			q = append(q, entry)
+			qBytes += entry.size
 		case rerr == ErrAsyncResponse:
 			// message handled but the response will come later
 		default:
-			c.reply(entry, result, rerr)
+			// anything else means the message is fully handled
+			addReply(entry, result, rerr)
+		}
+	}
+	// pending holds the requests read while throttled, in order.
+	// The stream is read even when there is no room for more requests, as it
+	// also carries the responses to our own calls, which a handler may be
+	// waiting for, but only until pending is as full as the queue.
+	var pending []*incoming
+	var pendingBytes int64
+	ok := true
+	for {
+		for len(pending) > 0 && !throttled() {
+			entry := pending[0]
+			pending = pending[1:]
+			pendingBytes -= entry.size
+			admit(entry)
+		}
+		// the queue is never throttled when empty, so pending is empty too
+		if len(q) == 0 && len(replies) == 0 && !ok {
+			return
+		}
+		read := fromRead
+		if !ok || limited(len(pending), pendingBytes) || limited(len(replies), repliesBytes) {
+			// stop reading, so that the peer is held back
+			read = nil
+		}
+		var deliver chan<- *incoming
+		var next *incoming
+		if len(q) > 0 {
+			deliver, next = toDeliver, q[0]
+		}
+		var reply chan<- *queuedReply
+		var nextReply *queuedReply
+		if len(replies) > 0 {
+			reply, nextReply = toReply, replies[0]
+		}
+		var entry *incoming
+		select {
+		case entry, ok = <-read:
+		case deliver <- next:
+			// TODO: this causes a lot of shuffling, should we use a growing ring buffer? compaction?
+			qBytes -= q[0].size
+			q = q[1:]
+		case reply <- nextReply:
+			repliesBytes -= replies[0].entry.size
+			replies = replies[1:]
+		}
+		switch {
+		case entry == nil:
+		case len(pending) > 0 || throttled():
+			pending = append(pending, entry)
+			pendingBytes += entry.size
+		default:
+			admit(entry)
 		}
 	}
 }
 
+// queuedReply is a reply made by the queue, waiting to be written.
+type queuedReply struct {
+	entry  *incoming
+	result interface{}
+	err    error
+}
+
+func (c *Connection) deliverMessages(ctx context.Context, options ConnectionOptions, fromQueue <-chan *incoming) {
+	defer c.async.done()
+	if options.Concurrency < 2 || options.Parallel == nil {
+		for entry := range fromQueue {
+			c.handle(options.Handler, entry)
+		}
+		return
+	}
+	// parallel requests run in their own goroutines, limited by slots
+	slots := make(chan struct{}, options.Concurrency)
+	var running sync.WaitGroup
+	defer running.Wait()
+	for entry := range fromQueue {
+		if !options.Parallel(entry.request) {
+			// wait for everything before it, and block everything after it
+			running.Wait()
+			c.handle(options.Handler, entry)
+			continue
+		}
+		slots <- struct{}{}
+		running.Add(1)
+		go func(entry *incoming) {
+			defer func() {
+				<-slots
+				running.Done()
+			}()
+			c.handle(options.Handler, entry)
+		}(entry)
+	}
+}
+
+// handle delivers a queued request to the handler, and replies to it.
+func (c *Connection) handle(handler Handler, entry *incoming) {
+	// cancel any messages in the queue that we have a pending cancel for
+	var result interface{}
+	rerr := entry.handleCtx.Err()
+	if rerr == nil {
+		// only deliver if not already cancelled
+		result, rerr = handler.Handle(entry.handleCtx, entry.request)
+	}
+	switch {
+	case rerr == ErrNotHandled:
+		// message not handled, report it back to the caller as an error
+		c.reply(entry, nil, errors.Errorf("%w: %q", ErrMethodNotFound, entry.request.Method))
+	case rerr == ErrAsyncResponse:
+		// message handled but the response will come later
+	default:
+		c.reply(entry, result, rerr)
+	}
+}
+
 // reply is used to reply to an incoming request that has just been handled
 func (c *Connection) reply(entry *incoming, result interface{}, rerr error) {
 	if entry.request.IsCall() {
-		// we have a call finishing, remove it from the incoming map
+		// we have a call finishing, remove it from the incoming map, which is
+		// released before writing as the reader needs it to go on
 		pending := <-c.incomingBox
-		defer func() { c.incomingBox <- pending }()
 		delete(pending, entry.request.ID)
+		cancelled := entry.cancelled
+		c.incomingBox <- pending
+		if cancelled && errors.Is(rerr, context.Canceled) {
+			rerr = ErrRequestCancelled
+		}
 	}
 	if err := c.respond(entry, result, rerr); err != nil {
 		// no way to propagate this error
@@ -430,7 +830,16 @@ func (c *Connection) respond(entry *incoming, result interface{}, rerr error) er
 		}
 		var response *Response
 		response, err = NewResponse(entry.request.ID, result, rerr)
-		if err == nil {
+		switch {
+		case entry.batch != nil:
+			if err != nil {
+				// the batch still needs a response for this call
+				response.Error = errors.Errorf("%w: marshaling result of %q: %v", ErrInternal, entry.request.Method, err)
+			}
+			if berr := c.batchRespond(entry.baseCtx, entry.batch, response); err == nil {
+				err = berr
+			}
+		case err == nil:
 			// we write the response with the base context, in case the message was cancelled
 			err = c.write(entry.baseCtx, response)
 		}
@@ -461,9 +870,23 @@ func (c *Connection) respond(entry *incoming, result interface{}, rerr error) er
 	}
 	// mark the entire request processing as done
 	event.End(entry.baseCtx, StatusCode(status))
+	atomic.AddInt64(&c.active, -1)
 	return err
 }
 
+// batchRespond adds a response to an incoming batch, and sends all the
+// responses once there is one for each call of the batch.
+func (c *Connection) batchRespond(ctx context.Context, batch *incomingBatch, response *Response) error {
+	batch.mu.Lock()
+	batch.responses = append(batch.responses, response)
+	complete := len(batch.responses) == batch.calls
+	batch.mu.Unlock()
+	if !complete {
+		return nil
+	}
+	return c.write(ctx, batch.responses)
+}
+
 // write is used by all things that write outgoing messages, including replies.
 // it makes sure that writes are atomic
 func (c *Connection) write(ctx context.Context, msg Message) error {
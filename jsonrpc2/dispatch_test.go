@@ -0,0 +1,193 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// tracker is a handler that records when each request starts and finishes.
+// Requests for "block" wait until release is closed.
+type tracker struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	log     []string
+	running int
+	max     int // the largest value of running
+}
+
+func (tr *tracker) Handle(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+	var name string
+	if err := json.Unmarshal(req.Params, &name); err != nil {
+		return nil, err
+	}
+	tr.record("start " + name)
+	tr.mu.Lock()
+	tr.running++
+	if tr.running > tr.max {
+		tr.max = tr.running
+	}
+	tr.mu.Unlock()
+	if req.Method == "block" {
+		select {
+		case <-tr.release:
+		case <-time.After(5 * time.Second):
+		}
+	} else {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tr.mu.Lock()
+	tr.running--
+	tr.mu.Unlock()
+	tr.record("end " + name)
+	return name, nil
+}
+
+func (tr *tracker) record(s string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.log = append(tr.log, s)
+}
+
+// position returns the position of s in the log.
+func (tr *tracker) position(t *testing.T, s string) int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for i, l := range tr.log {
+		if l == s {
+			return i
+		}
+	}
+	t.Fatalf("%q is not in the log %q", s, tr.log)
+	return -1
+}
+
+// startTracker starts a server that handles requests with a tracker, and
+// returns a client connected to it.
+func startTracker(t *testing.T, ctx context.Context, concurrency int, parallel func(*jsonrpc2.Request) bool) (*tracker, *jsonrpc2.Connection, func()) {
+	tr := &tracker{release: make(chan struct{})}
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, jsonrpc2.ConnectionOptions{
+		Handler:     tr,
+		Concurrency: concurrency,
+		Parallel:    parallel,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr, client, func() {
+		listener.Close()
+		client.Close()
+		server.Wait()
+	}
+}
+
+func awaitAll(t *testing.T, ctx context.Context, calls []*jsonrpc2.AsyncCall) {
+	for _, call := range calls {
+		if err := call.Await(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParallel(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tr, client, shutdown := startTracker(t, ctx, 3, func(*jsonrpc2.Request) bool { return true })
+	defer shutdown()
+
+	// the three blocked calls can only all start if they run in parallel
+	var calls []*jsonrpc2.AsyncCall
+	for i := 0; i < 3; i++ {
+		calls = append(calls, client.Call(ctx, "block", fmt.Sprint(i)))
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		tr.mu.Lock()
+		running := tr.running
+		tr.mu.Unlock()
+		if running == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d calls running, want 3", running)
+		}
+	}
+	close(tr.release)
+	awaitAll(t, ctx, calls)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tr, client, shutdown := startTracker(t, ctx, 2, jsonrpc2.ParallelMethods("sleep"))
+	defer shutdown()
+
+	var calls []*jsonrpc2.AsyncCall
+	for i := 0; i < 6; i++ {
+		calls = append(calls, client.Call(ctx, "sleep", fmt.Sprint(i)))
+	}
+	awaitAll(t, ctx, calls)
+	if tr.max != 2 {
+		t.Errorf("at most %d calls ran at once, want 2", tr.max)
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tr, client, shutdown := startTracker(t, ctx, 4, jsonrpc2.ParallelMethods("sleep"))
+	defer shutdown()
+
+	calls := []*jsonrpc2.AsyncCall{
+		client.Call(ctx, "sleep", "a"),
+		client.Call(ctx, "sleep", "b"),
+		client.Call(ctx, "ordered", "x"),
+		client.Call(ctx, "sleep", "c"),
+		client.Call(ctx, "ordered", "y"),
+		client.Call(ctx, "ordered", "z"),
+	}
+	awaitAll(t, ctx, calls)
+	before := func(first, second string) {
+		if tr.position(t, first) > tr.position(t, second) {
+			t.Errorf("%q happened after %q: %q", first, second, tr.log)
+		}
+	}
+	before("end a", "start x")
+	before("end b", "start x")
+	before("end x", "start c")
+	before("end c", "start y")
+	before("end y", "start z")
+
+	// with no Parallel policy, nothing overlaps
+	tr, client, shutdown = startTracker(t, ctx, 4, nil)
+	defer shutdown()
+	calls = calls[:0]
+	for i := 0; i < 3; i++ {
+		calls = append(calls, client.Call(ctx, "sleep", fmt.Sprint(i)))
+	}
+	awaitAll(t, ctx, calls)
+	if tr.max != 1 {
+		t.Errorf("%d calls ran at once without a Parallel policy, want 1", tr.max)
+	}
+}
@@ -6,12 +6,17 @@ package jsonrpc2
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 
 	errors "golang.org/x/xerrors"
 )
@@ -98,12 +103,15 @@ func (w *rawWriter) Write(ctx context.Context, msg Message) (int64, error) {
 // This is the format used by LSP and others.
 func HeaderFramer() Framer { return headerFramer{} }
 
-type headerFramer struct{}
-type headerReader struct{ in *bufio.Reader }
+type headerFramer struct{ max int64 }
+type headerReader struct {
+	in  *bufio.Reader
+	max int64
+}
 type headerWriter struct{ out io.Writer }
 
-func (headerFramer) Reader(rw io.Reader) Reader {
-	return &headerReader{in: bufio.NewReader(rw)}
+func (f headerFramer) Reader(rw io.Reader) Reader {
+	return &headerReader{in: bufio.NewReader(rw), max: f.max}
 }
 
 func (headerFramer) Writer(rw io.Writer) Writer {
@@ -117,7 +125,8 @@ func (r *headerReader) Read(ctx context.Context) (Message, int64, error) {
 	default:
 	}
 	var total, length int64
-	// read the header, stop on the first empty line
+	headers := false
+	// read the header, stop on the first empty line after it
 	for {
 		line, err := r.in.ReadString('\n')
 		total += int64(len(line))
@@ -125,10 +134,14 @@ func (r *headerReader) Read(ctx context.Context) (Message, int64, error) {
 			return nil, total, errors.Errorf("failed reading header line: %w", err)
 		}
 		line = strings.TrimSpace(line)
-		// check we have a header line
+		// check we have a header line, blank lines before it are padding
 		if line == "" {
-			break
+			if headers {
+				break
+			}
+			continue
 		}
+		headers = true
 		colon := strings.IndexRune(line, ':')
 		if colon < 0 {
 			return nil, total, errors.Errorf("invalid header line %q", line)
@@ -142,6 +155,9 @@ func (r *headerReader) Read(ctx context.Context) (Message, int64, error) {
 			if length <= 0 {
 				return nil, total, errors.Errorf("invalid Content-Length: %v", length)
 			}
+			if r.max > 0 && length > r.max {
+				return nil, total, errors.Errorf("%w: Content-Length %v", ErrMessageTooLarge, length)
+			}
 		default:
 			// ignoring unknown headers
 		}
@@ -177,3 +193,358 @@ func (w *headerWriter) Write(ctx context.Context, msg Message) (int64, error) {
 	}
 	return total, err
 }
+
+// NDJSONFramer returns a new Framer.
+// The messages are sent as newline delimited JSON, one message per line.
+// Blank lines between messages are ignored.
+// Messages larger than maxSize bytes are rejected with ErrMessageTooLarge,
+// a maxSize of zero or less means there is no limit.
+func NDJSONFramer(maxSize int64) Framer { return ndjsonFramer{max: maxSize} }
+
+type ndjsonFramer struct{ max int64 }
+type ndjsonReader struct {
+	in  *bufio.Reader
+	max int64
+}
+type ndjsonWriter struct {
+	out io.Writer
+	max int64
+}
+
+func (f ndjsonFramer) Reader(rw io.Reader) Reader {
+	return &ndjsonReader{in: bufio.NewReader(rw), max: f.max}
+}
+
+func (f ndjsonFramer) Writer(rw io.Writer) Writer {
+	return &ndjsonWriter{out: rw, max: f.max}
+}
+
+func (r *ndjsonReader) Read(ctx context.Context) (Message, int64, error) {
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+	var total int64
+	for {
+		line, err := r.readLine()
+		total += int64(len(line))
+		if err != nil {
+			return nil, total, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		msg, err := DecodeMessage(line)
+		return msg, total, err
+	}
+}
+
+// readLine reads up to and including the next newline, without ever holding
+// more than the maximum message size in memory.
+// A final line with no newline is returned as is, the end of the stream is
+// reported by the next call.
+func (r *ndjsonReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.in.ReadSlice('\n')
+		line = append(line, chunk...)
+		if r.max > 0 && int64(len(bytes.TrimRight(line, "\r\n"))) > r.max {
+			return line, errors.Errorf("%w: line longer than %v bytes", ErrMessageTooLarge, r.max)
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && len(bytes.TrimSpace(line)) > 0:
+			return line, nil
+		default:
+			return line, err
+		}
+	}
+}
+
+func (w *ndjsonWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+	data, err := EncodeMessage(msg)
+	if err != nil {
+		return 0, errors.Errorf("marshaling message: %v", err)
+	}
+	if w.max > 0 && int64(len(data)) > w.max {
+		return 0, errors.Errorf("%w: %v bytes", ErrMessageTooLarge, len(data))
+	}
+	// encoding/json never writes a raw newline, so the message is a single line
+	n, err := w.out.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// LengthPrefixFramer returns a new Framer.
+// Each message is preceded by its length in bytes, as a 4 byte big endian
+// unsigned integer.
+// Messages larger than maxSize bytes are rejected with ErrMessageTooLarge,
+// a maxSize of zero or less means only the limit of the prefix applies.
+func LengthPrefixFramer(maxSize int64) Framer { return lengthPrefixFramer{max: maxSize} }
+
+type lengthPrefixFramer struct{ max int64 }
+type lengthPrefixReader struct {
+	in  *bufio.Reader
+	max int64
+}
+type lengthPrefixWriter struct {
+	out io.Writer
+	max int64
+}
+
+func (f lengthPrefixFramer) Reader(rw io.Reader) Reader {
+	return &lengthPrefixReader{in: bufio.NewReader(rw), max: f.max}
+}
+
+func (f lengthPrefixFramer) Writer(rw io.Writer) Writer {
+	return &lengthPrefixWriter{out: rw, max: f.max}
+}
+
+func (r *lengthPrefixReader) Read(ctx context.Context) (Message, int64, error) {
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+	var prefix [4]byte
+	n, err := io.ReadFull(r.in, prefix[:])
+	total := int64(n)
+	if err != nil {
+		return nil, total, err
+	}
+	length := int64(binary.BigEndian.Uint32(prefix[:]))
+	if length == 0 {
+		return nil, total, errors.Errorf("invalid message length: %v", length)
+	}
+	if r.max > 0 && length > r.max {
+		return nil, total, errors.Errorf("%w: %v bytes", ErrMessageTooLarge, length)
+	}
+	// grow the buffer as the data arrives rather than trusting the prefix
+	data, err := io.ReadAll(io.LimitReader(r.in, length))
+	total += int64(len(data))
+	if err == nil && int64(len(data)) < length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, total, err
+	}
+	msg, err := DecodeMessage(data)
+	return msg, total, err
+}
+
+func (w *lengthPrefixWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+	data, err := EncodeMessage(msg)
+	if err != nil {
+		return 0, errors.Errorf("marshaling message: %v", err)
+	}
+	if (w.max > 0 && int64(len(data)) > w.max) || int64(len(data)) > math.MaxUint32 {
+		return 0, errors.Errorf("%w: %v bytes", ErrMessageTooLarge, len(data))
+	}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	n, err := w.out.Write(frame)
+	return int64(n), err
+}
+
+// DetectFramer returns a Framer that detects the framing of the peer from the
+// first bytes it reads.
+// A stream starting with a Content-Length header is read as by HeaderFramer,
+// one starting with a JSON object or array as by NDJSONFramer, and one
+// starting with a plausible length prefix, that is a length below 16 MiB or
+// no larger than maxSize, as by LengthPrefixFramer.
+// Any other stream, such as one starting with a byte order mark, fails to
+// read.
+// Messages are written with the framing detected on the same stream, or with
+// fallback if they are written before the first message was read, as when
+// this side speaks first. A nil fallback means NDJSONFramer.
+// The reader and writer of a stream are paired by passing the same value to
+// Reader and Writer, as a Connection does, before either of them is used.
+// All the framings reject messages larger than maxSize bytes.
+func DetectFramer(maxSize int64, fallback Framer) Framer {
+	if fallback == nil {
+		fallback = NDJSONFramer(maxSize)
+	}
+	return &detectFramer{
+		max:      maxSize,
+		fallback: fallback,
+		pending:  make(map[interface{}]*detected),
+	}
+}
+
+type detectFramer struct {
+	max      int64
+	fallback Framer
+
+	mu      sync.Mutex
+	pending map[interface{}]*detected
+}
+
+// detected holds the framing detected on a stream, it is shared by the reader
+// and writer of the stream.
+type detected struct {
+	mu     sync.Mutex
+	framer Framer
+}
+
+type detectReader struct {
+	framer *detectFramer
+	stream io.Reader // until it is used, for unpair
+	in     *bufio.Reader
+	max    int64
+	state  *detected
+	reader Reader
+}
+
+type detectWriter struct {
+	framer   *detectFramer
+	stream   io.Writer // until it is used, for unpair
+	out      io.Writer
+	fallback Framer
+	state    *detected
+	writer   Writer
+}
+
+func (f *detectFramer) Reader(rw io.Reader) Reader {
+	return &detectReader{framer: f, stream: rw, in: bufio.NewReader(rw), max: f.max, state: f.pair(rw)}
+}
+
+func (f *detectFramer) Writer(rw io.Writer) Writer {
+	return &detectWriter{framer: f, stream: rw, out: rw, fallback: f.fallback, state: f.pair(rw)}
+}
+
+// pair returns the state shared by the reader and writer of the stream.
+// The first of them to be made for a stream leaves the state for the second,
+// which takes it back out of the pending map. If the second is never made,
+// the state is taken out by unpair when the first is used.
+// Streams that cannot be used as map keys are never paired, and write with
+// the fallback framing.
+func (f *detectFramer) pair(stream interface{}) *detected {
+	if !reflect.TypeOf(stream).Comparable() {
+		return &detected{}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if d, ok := f.pending[stream]; ok {
+		delete(f.pending, stream)
+		return d
+	}
+	d := &detected{}
+	f.pending[stream] = d
+	return d
+}
+
+// unpair drops the state of the stream if it is still pending, so the
+// framer does not keep streams that only have a reader or a writer.
+func (f *detectFramer) unpair(stream interface{}) {
+	if !reflect.TypeOf(stream).Comparable() {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.pending, stream)
+}
+
+func (d *detected) set(framer Framer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.framer = framer
+}
+
+func (d *detected) get() Framer {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.framer
+}
+
+func (r *detectReader) Read(ctx context.Context) (Message, int64, error) {
+	if r.reader != nil {
+		return r.reader.Read(ctx)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+	if r.stream != nil {
+		r.framer.unpair(r.stream)
+		r.stream = nil
+	}
+	framer, skipped, err := r.detect()
+	if err != nil {
+		return nil, skipped, err
+	}
+	r.state.set(framer)
+	// the buffered reader is reused as is, so no peeked bytes are lost
+	r.reader = framer.Reader(r.in)
+	msg, n, err := r.reader.Read(ctx)
+	return msg, skipped + n, err
+}
+
+// detect peeks at the start of the stream to pick its framing, discarding any
+// leading white space.
+func (r *detectReader) detect() (Framer, int64, error) {
+	for i := 1; ; i++ {
+		peek, err := r.in.Peek(i)
+		if err != nil {
+			return nil, 0, err
+		}
+		var framer Framer
+		switch b := peek[i-1]; {
+		case b == ' ' || b == '\t' || b == '\r' || b == '\n':
+			continue
+		case b == '{' || b == '[':
+			framer = NDJSONFramer(r.max)
+		case b == 'C' || b == 'c':
+			framer = headerFramer{max: r.max}
+		case i == 1 && r.lengthPrefixed():
+			framer = LengthPrefixFramer(r.max)
+		default:
+			if prefix, _ := r.in.Peek(i + 3); len(prefix) > len(peek) {
+				peek = prefix
+			}
+			return nil, 0, errors.Errorf("cannot detect framing from %q", peek)
+		}
+		n, err := r.in.Discard(i - 1)
+		return framer, int64(n), err
+	}
+}
+
+// lengthPrefixed reports whether the stream starts with a plausible length
+// prefix: one whose first byte is zero, or one no larger than the maximum
+// message size.
+func (r *detectReader) lengthPrefixed() bool {
+	prefix, err := r.in.Peek(4)
+	if err != nil || bytes.HasPrefix(prefix, []byte("\xEF\xBB\xBF")) {
+		return false
+	}
+	length := int64(binary.BigEndian.Uint32(prefix))
+	return length > 0 && (prefix[0] == 0 || length <= r.max)
+}
+
+func (w *detectWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	if w.stream != nil {
+		w.framer.unpair(w.stream)
+		w.stream = nil
+	}
+	if w.writer == nil {
+		framer := w.state.get()
+		if framer == nil {
+			framer = w.fallback
+		}
+		w.writer = framer.Writer(w.out)
+	}
+	return w.writer.Write(ctx, msg)
+}
@@ -0,0 +1,263 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+var frameMessages = []jsonrpc2.Message{
+	newNotification("alive", nil),
+	newCall("msg1", "ping", nil),
+	newCall(1, "poke", map[string]string{"text": "multi\nline"}),
+	newResponse("msg2", "pong", nil),
+	newResponse(3, nil, jsonrpc2.NewError(0, "computing fix edits")),
+	jsonrpc2.Batch{
+		newCall(1, "ping", nil),
+		newNotification("alive", nil),
+	},
+}
+
+func TestFramerRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		writer jsonrpc2.Framer
+		reader jsonrpc2.Framer
+	}{
+		{"ndjson", jsonrpc2.NDJSONFramer(1024), jsonrpc2.NDJSONFramer(1024)},
+		{"length prefix", jsonrpc2.LengthPrefixFramer(1024), jsonrpc2.LengthPrefixFramer(1024)},
+		{"detect header", jsonrpc2.HeaderFramer(), jsonrpc2.DetectFramer(1024, nil)},
+		{"detect ndjson", jsonrpc2.NDJSONFramer(0), jsonrpc2.DetectFramer(1024, nil)},
+		{"detect length prefix", jsonrpc2.LengthPrefixFramer(0), jsonrpc2.DetectFramer(1024, nil)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			var buf bytes.Buffer
+			writer := test.writer.Writer(&buf)
+			var written int64
+			for _, msg := range frameMessages {
+				n, err := writer.Write(ctx, msg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				written += n
+			}
+			if written != int64(buf.Len()) {
+				t.Errorf("wrote %d bytes, reported %d", buf.Len(), written)
+			}
+			reader := test.reader.Reader(&buf)
+			var read int64
+			for _, want := range frameMessages {
+				msg, n, err := reader.Read(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				read += n
+				if !reflect.DeepEqual(msg, want) {
+					t.Errorf("read message does not match\nGot:\n%+#v\nWant:\n%+#v", msg, want)
+				}
+			}
+			if _, _, err := reader.Read(ctx); !errors.Is(err, io.EOF) {
+				t.Errorf("read at end of stream got %v, want EOF", err)
+			}
+			if read != written {
+				t.Errorf("read %d bytes, wrote %d", read, written)
+			}
+		})
+	}
+}
+
+func TestFramerErrors(t *testing.T) {
+	alive := `{"jsonrpc":"2.0","method":"alive"}`
+	for _, test := range []struct {
+		name   string
+		framer jsonrpc2.Framer
+		input  string
+		want   error // nil for any error
+	}{
+		{"ndjson too large", jsonrpc2.NDJSONFramer(16), alive + "\n", jsonrpc2.ErrMessageTooLarge},
+		{"ndjson too large unterminated", jsonrpc2.NDJSONFramer(16), strings.Repeat(" x", 5000), jsonrpc2.ErrMessageTooLarge},
+		{"ndjson invalid", jsonrpc2.NDJSONFramer(0), "{not json}\n", nil},
+		{"ndjson two per line", jsonrpc2.NDJSONFramer(0), alive + alive + "\n", nil},
+		{"ndjson truncated", jsonrpc2.NDJSONFramer(0), alive[:10], nil},
+		{"prefix truncated", jsonrpc2.LengthPrefixFramer(0), "\x00\x00", io.ErrUnexpectedEOF},
+		{"prefix zero", jsonrpc2.LengthPrefixFramer(0), "\x00\x00\x00\x00", nil},
+		{"prefix too large", jsonrpc2.LengthPrefixFramer(1024), "\x00\x01\x00\x00" + alive, jsonrpc2.ErrMessageTooLarge},
+		{"prefix truncated body", jsonrpc2.LengthPrefixFramer(0), "\x00\x00\x00\x40" + alive, io.ErrUnexpectedEOF},
+		{"prefix invalid", jsonrpc2.LengthPrefixFramer(0), "\x00\x00\x00\x04null", nil},
+		{"detect header too large", jsonrpc2.DetectFramer(16, nil), "Content-Length: 34\r\n\r\n" + alive, jsonrpc2.ErrMessageTooLarge},
+		{"detect ndjson too large", jsonrpc2.DetectFramer(16, nil), "\n\n" + alive + "\n", jsonrpc2.ErrMessageTooLarge},
+		{"detect unknown", jsonrpc2.DetectFramer(0, nil), "  \x00\x00\x00\x01", nil},
+		{"detect garbage", jsonrpc2.DetectFramer(1024, nil), "hello world\n", nil},
+		{"detect byte order mark", jsonrpc2.DetectFramer(0, nil), "\xEF\xBB\xBF" + alive + "\n", nil},
+		{"detect prefix too large", jsonrpc2.DetectFramer(16, nil), "\x00\x00\x00\x22" + alive, jsonrpc2.ErrMessageTooLarge},
+		{"detect empty", jsonrpc2.DetectFramer(0, nil), "", io.EOF},
+	} {
+		reader := test.framer.Reader(strings.NewReader(test.input))
+		msg, _, err := reader.Read(context.Background())
+		switch {
+		case err == nil:
+			t.Errorf("%s: read %q without error as %+v", test.name, test.input, msg)
+		case test.want != nil && !errors.Is(err, test.want):
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.want)
+		}
+	}
+	for _, framer := range []jsonrpc2.Framer{
+		jsonrpc2.NDJSONFramer(16),
+		jsonrpc2.LengthPrefixFramer(16),
+	} {
+		var buf bytes.Buffer
+		_, err := framer.Writer(&buf).Write(context.Background(), newNotification("alive", nil))
+		if !errors.Is(err, jsonrpc2.ErrMessageTooLarge) {
+			t.Errorf("%T: writing large message got %v, want ErrMessageTooLarge", framer, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%T: wrote %q for a rejected message", framer, buf.Bytes())
+		}
+	}
+}
+
+// TestFramerPadding checks that white space around the messages of a header
+// stream is skipped.
+func TestFramerPadding(t *testing.T) {
+	alive := "Content-Length: 34\r\n\r\n" + `{"jsonrpc":"2.0","method":"alive"}`
+	input := "\r\n" + alive + "\r\n\n" + alive + "\n"
+	for _, framer := range []jsonrpc2.Framer{
+		jsonrpc2.HeaderFramer(),
+		jsonrpc2.DetectFramer(1024, nil),
+	} {
+		reader := framer.Reader(strings.NewReader(input))
+		for i := 0; i < 2; i++ {
+			if _, _, err := reader.Read(context.Background()); err != nil {
+				t.Fatalf("%T: reading message %d: %v", framer, i, err)
+			}
+		}
+		if _, _, err := reader.Read(context.Background()); !errors.Is(err, io.EOF) {
+			t.Errorf("%T: reading past the end got %v, want io.EOF", framer, err)
+		}
+	}
+}
+
+// TestDetectFramerReply checks that a detecting framer replies with the
+// framing of the peer, and otherwise with its fallback.
+func TestDetectFramerReply(t *testing.T) {
+	ctx := context.Background()
+	for _, peer := range []jsonrpc2.Framer{
+		jsonrpc2.HeaderFramer(),
+		jsonrpc2.NDJSONFramer(0),
+		jsonrpc2.LengthPrefixFramer(0),
+	} {
+		stream := &loopback{}
+		peer.Writer(&stream.in).Write(ctx, newCall(1, "ping", nil))
+		framer := jsonrpc2.DetectFramer(1024, jsonrpc2.LengthPrefixFramer(1024))
+		reader, writer := framer.Reader(stream), framer.Writer(stream)
+		if _, _, err := reader.Read(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write(ctx, newResponse(1, "pong", nil)); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := peer.Reader(&stream.out).Read(ctx); err != nil {
+			t.Errorf("%T: reading reply: %v", peer, err)
+		}
+	}
+	// nothing read yet, so the fallback is used
+	stream := &loopback{}
+	framer := jsonrpc2.DetectFramer(1024, jsonrpc2.LengthPrefixFramer(1024))
+	framer.Reader(stream)
+	if _, err := framer.Writer(stream).Write(ctx, newNotification("alive", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := jsonrpc2.LengthPrefixFramer(0).Reader(&stream.out).Read(ctx); err != nil {
+		t.Errorf("reading fallback message: %v", err)
+	}
+}
+
+// loopback is a stream that reads from in and writes to out.
+type loopback struct{ in, out bytes.Buffer }
+
+func (l *loopback) Read(p []byte) (int, error)  { return l.in.Read(p) }
+func (l *loopback) Write(p []byte) (int, error) { return l.out.Write(p) }
+
+func FuzzFramers(f *testing.F) {
+	ctx := context.Background()
+	for _, framer := range []jsonrpc2.Framer{
+		jsonrpc2.HeaderFramer(),
+		jsonrpc2.NDJSONFramer(0),
+		jsonrpc2.LengthPrefixFramer(0),
+	} {
+		var buf bytes.Buffer
+		writer := framer.Writer(&buf)
+		for _, msg := range frameMessages {
+			writer.Write(ctx, msg)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Add([]byte("\x00\x00\x00\x00"))
+	f.Add([]byte("\xff\xff\xff\xff{}"))
+	f.Add([]byte("Content-Length: 99999999\r\n\r\n"))
+	f.Add([]byte("{\"jsonrpc\":\"2.0\"\r\n\n\n[]\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		const max = 1024
+		for _, framer := range []jsonrpc2.Framer{
+			jsonrpc2.NDJSONFramer(max),
+			jsonrpc2.LengthPrefixFramer(max),
+			jsonrpc2.DetectFramer(max, nil),
+		} {
+			reader := framer.Reader(bytes.NewReader(data))
+			var total int64
+			for i := 0; i < 100; i++ {
+				msg, n, err := reader.Read(ctx)
+				total += n
+				if err != nil {
+					break
+				}
+				checkFrameRoundTrip(t, msg)
+			}
+			if total > int64(len(data)) {
+				t.Errorf("%T: read %d bytes from %d bytes of input", framer, total, len(data))
+			}
+		}
+	})
+}
+
+// checkFrameRoundTrip checks that msg is read back unchanged after writing it
+// with each framer.
+func checkFrameRoundTrip(t *testing.T, msg jsonrpc2.Message) {
+	want, err := jsonrpc2.EncodeMessage(msg)
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	for _, framer := range []jsonrpc2.Framer{
+		jsonrpc2.HeaderFramer(),
+		jsonrpc2.NDJSONFramer(0),
+		jsonrpc2.LengthPrefixFramer(0),
+	} {
+		var buf bytes.Buffer
+		if _, err := framer.Writer(&buf).Write(ctx, msg); err != nil {
+			t.Fatalf("%T: writing %s: %v", framer, want, err)
+		}
+		got, _, err := framer.Reader(&buf).Read(ctx)
+		if err != nil {
+			t.Fatalf("%T: reading back %s: %v", framer, want, err)
+		}
+		encoded, err := jsonrpc2.EncodeMessage(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, want) {
+			t.Errorf("%T: round trip changed message\nGot:\n%s\nWant:\n%s", framer, encoded, want)
+		}
+	}
+}
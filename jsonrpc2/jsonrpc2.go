@@ -15,6 +15,12 @@ import (
 var (
 	// ErrIdleTimeout is returned when serving timed out waiting for new connections.
 	ErrIdleTimeout = errors.New("timed out waiting for new connections")
+	// ErrPeerTimeout is the error of a connection closed by its Keepalive
+	// because the peer stopped responding.
+	ErrPeerTimeout = errors.New("timed out waiting for the peer")
+	// ErrMessageTooLarge is returned by framers when a message exceeds their
+	// maximum size.
+	ErrMessageTooLarge = errors.New("message exceeds the maximum size")
 	// ErrNotHandled is returned from a handler to indicate it did not handle the
 	// message.
 	ErrNotHandled = errors.New("JSON RPC not handled")
@@ -90,6 +96,14 @@ func (a *async) wait() error {
 	return err
 }
 
+// err returns the error set so far, without waiting for the result to be
+// ready.
+func (a *async) err() error {
+	err := <-a.errBox
+	a.errBox <- err
+	return err
+}
+
 func (a *async) setError(err error) {
 	storedErr := <-a.errBox
 	if storedErr == nil {
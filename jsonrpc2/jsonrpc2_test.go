@@ -8,6 +8,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"path"
 	"reflect"
 	"strings"
@@ -62,6 +63,20 @@ var callTests = []invoker{
 		collect{"a", true, false},
 	}},
 	callErr{"error", func() {}, "marshaling call parameters: json: unsupported type"},
+	batch{"batch", []invoker{
+		notify{"set", 3},
+		call{"one_string", "fish", "got:fish"},
+		notify{"add", 5},
+		call{"get", nil, 8},
+		call{"join", []string{"a", "b", "c"}, "a/b/c"},
+	}},
+	sequence{"notify batch", []invoker{
+		batch{"notifications", []invoker{
+			notify{"set", 1},
+			notify{"add", 2},
+		}},
+		call{"get", nil, 3},
+	}},
 }
 
 type binder struct {
@@ -119,6 +134,11 @@ type sequence struct {
 	tests []invoker
 }
 
+type batch struct {
+	name  string
+	tests []invoker // calls and notifications only
+}
+
 type echo call
 
 type cancelParams struct{ ID int64 }
@@ -131,14 +151,36 @@ func TestConnectionHeader(t *testing.T) {
 	testConnection(t, jsonrpc2.HeaderFramer())
 }
 
+func TestConnectionNDJSON(t *testing.T) {
+	testConnection(t, jsonrpc2.NDJSONFramer(1<<20))
+}
+
+func TestConnectionLengthPrefix(t *testing.T) {
+	testConnection(t, jsonrpc2.LengthPrefixFramer(1<<20))
+}
+
+func TestConnectionDetect(t *testing.T) {
+	for _, framer := range []jsonrpc2.Framer{
+		jsonrpc2.HeaderFramer(),
+		jsonrpc2.NDJSONFramer(0),
+		jsonrpc2.LengthPrefixFramer(0),
+	} {
+		testConnectionFramers(t, jsonrpc2.DetectFramer(1<<20, nil), framer)
+	}
+}
+
 func testConnection(t *testing.T, framer jsonrpc2.Framer) {
+	testConnectionFramers(t, framer, framer)
+}
+
+func testConnectionFramers(t *testing.T, serverFramer, clientFramer jsonrpc2.Framer) {
 	stacktest.NoLeak(t)
 	ctx := eventtest.NewContext(context.Background(), t)
 	listener, err := jsonrpc2.NetPipe(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
-	server, err := jsonrpc2.Serve(ctx, listener, binder{framer, nil})
+	server, err := jsonrpc2.Serve(ctx, listener, binder{serverFramer, nil})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -150,7 +192,7 @@ func testConnection(t *testing.T, framer jsonrpc2.Framer) {
 	for _, test := range callTests {
 		t.Run(test.Name(), func(t *testing.T) {
 			client, err := jsonrpc2.Dial(ctx,
-				listener.Dialer(), binder{framer, func(h *handler) {
+				listener.Dialer(), binder{clientFramer, func(h *handler) {
 					defer h.conn.Close()
 					ctx := eventtest.NewContext(ctx, t)
 					test.Invoke(t, ctx, h)
@@ -237,6 +279,177 @@ func (test sequence) Invoke(t *testing.T, ctx context.Context, h *handler) {
 	}
 }
 
+func (test batch) Name() string { return test.name }
+func (test batch) Invoke(t *testing.T, ctx context.Context, h *handler) {
+	b := h.conn.NewBatch()
+	var calls []call
+	var results []interface{}
+	for _, child := range test.tests {
+		switch child := child.(type) {
+		case call:
+			b.Call(ctx, child.method, child.params)
+			calls = append(calls, child)
+			results = append(results, newResults(child.expect))
+		case notify:
+			if err := b.Notify(ctx, child.method, child.params); err != nil {
+				t.Fatalf("%v:Notify failed: %v", child.method, err)
+			}
+		default:
+			t.Fatalf("%v: cannot batch %T", test.name, child)
+		}
+	}
+	if err := b.Send(ctx); err != nil {
+		t.Fatalf("%v:Send failed: %v", test.name, err)
+	}
+	if err := b.Await(ctx, results...); err != nil {
+		t.Fatalf("%v:Await failed: %v", test.name, err)
+	}
+	for i, c := range calls {
+		verifyResults(t, c.method, results[i], c.expect)
+	}
+}
+
+// TestBatchWire checks the response to a batch sent by a peer that is not a
+// jsonrpc2.Connection.
+func TestBatchWire(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx := eventtest.NewContext(context.Background(), t)
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, binder{jsonrpc2.RawFramer(), nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+	rwc, err := listener.Dialer().Dial(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+
+	send := func(data string) {
+		t.Helper()
+		if _, err := io.WriteString(rwc, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := json.NewDecoder(rwc)
+	// the notifications get no responses, so the only responses are in an array
+	send(`[
+		{"jsonrpc":"2.0","id":1,"method":"one_string","params":"x"},
+		{"jsonrpc":"2.0","method":"set","params":4},
+		{"jsonrpc":"2.0","id":"two","method":"get"}
+	]`)
+	send(`[{"jsonrpc":"2.0","method":"add","params":1}]`)
+	send(`{"jsonrpc":"2.0","id":3,"method":"get"}`)
+	var got []json.RawMessage
+	for i := 0; i < 2; i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, raw)
+	}
+	want := []string{
+		`[{"jsonrpc":"2.0","id":1,"result":"got:x"},{"jsonrpc":"2.0","id":"two","result":4}]`,
+		`{"jsonrpc":"2.0","id":3,"result":5}`,
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Errorf("response %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+// TestBatchInvalid checks that invalid batches get error responses as the
+// specification requires, without closing the connection.
+func TestBatchInvalid(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx := eventtest.NewContext(context.Background(), t)
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, binder{jsonrpc2.RawFramer(), nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+	rwc, err := listener.Dialer().Dial(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+	dec := json.NewDecoder(rwc)
+
+	type response struct {
+		ID     json.RawMessage
+		Result json.RawMessage
+		Error  *struct{ Code int64 }
+	}
+	for _, test := range []struct {
+		name    string
+		request string
+		want    []response
+		single  bool // the response is a single object rather than an array
+	}{{
+		name:    "empty",
+		request: `[]`,
+		single:  true,
+		want:    []response{{ID: json.RawMessage(`null`), Error: &struct{ Code int64 }{-32600}}},
+	}, {
+		name:    "one invalid",
+		request: `[1]`,
+		want:    []response{{ID: json.RawMessage(`null`), Error: &struct{ Code int64 }{-32600}}},
+	}, {
+		name:    "all invalid",
+		request: `[1,2,3]`,
+		want: []response{
+			{ID: json.RawMessage(`null`), Error: &struct{ Code int64 }{-32600}},
+			{ID: json.RawMessage(`null`), Error: &struct{ Code int64 }{-32600}},
+			{ID: json.RawMessage(`null`), Error: &struct{ Code int64 }{-32600}},
+		},
+	}, {
+		name:    "mixed",
+		request: `[{"jsonrpc":"2.0","id":1,"method":"one_string","params":"x"},{"foo":"boo"},[]]`,
+		want: []response{
+			{ID: json.RawMessage(`null`), Error: &struct{ Code int64 }{-32600}},
+			{ID: json.RawMessage(`null`), Error: &struct{ Code int64 }{-32600}},
+			{ID: json.RawMessage(`1`), Result: json.RawMessage(`"got:x"`)},
+		},
+	}, {
+		name:    "still open",
+		request: `{"jsonrpc":"2.0","id":2,"method":"one_string","params":"y"}`,
+		single:  true,
+		want:    []response{{ID: json.RawMessage(`2`), Result: json.RawMessage(`"got:y"`)}},
+	}} {
+		if _, err := io.WriteString(rwc, test.request); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var got []response
+		if test.single {
+			got = make([]response, 1)
+			err = dec.Decode(&got[0])
+		} else {
+			err = dec.Decode(&got)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
 // newResults makes a new empty copy of the expected type to put the results into
 func newResults(expect interface{}) interface{} {
 	switch e := expect.(type) {
@@ -0,0 +1,139 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/event"
+)
+
+// Keepalive makes a connection ping its peer at a regular interval, and close
+// the connection with ErrPeerTimeout once nothing has been read from the peer
+// for longer than a timeout, so that a dead peer does not leave calls waiting
+// forever.
+// It is enabled with ConnectionOptions.Keepalive.
+//
+// Incoming pings are answered before they are queued, but a connection that
+// holds back requests stops reading once it holds back as many as its queue
+// does, so the timeout should allow for the longest wait for room in the queue.
+type Keepalive struct {
+	// Method is the method of the pings.
+	// If empty, "$/ping" is used.
+	Method string
+	// Call makes the pings calls, which the peer must answer, rather than
+	// notifications. A peer that only answers its own pings with calls is
+	// still detected when it stops responding.
+	Call bool
+	// Interval is the time between pings.
+	// If zero, 30 seconds is used.
+	Interval time.Duration
+	// Timeout is how long the connection waits without reading anything from
+	// the peer before it closes.
+	// If zero, three times the interval is used.
+	Timeout time.Duration
+	// Clock, if not nil, replaces the system clock, which is useful in tests.
+	Clock Clock
+}
+
+// Clock is a source of time.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel on which the time is sent once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// withDefaults returns a copy of k with the defaults filled in.
+func (k Keepalive) withDefaults() *Keepalive {
+	if k.Method == "" {
+		k.Method = "$/ping"
+	}
+	if k.Interval <= 0 {
+		k.Interval = 30 * time.Second
+	}
+	if k.Timeout <= 0 {
+		k.Timeout = 3 * k.Interval
+	}
+	if k.Clock == nil {
+		k.Clock = systemClock{}
+	}
+	return &k
+}
+
+// keepalivePreempter answers pings, and passes other requests on.
+type keepalivePreempter struct {
+	method string
+	next   Preempter
+}
+
+func (p keepalivePreempter) Preempt(ctx context.Context, req *Request) (interface{}, error) {
+	if req.Method != p.method {
+		return p.next.Preempt(ctx, req)
+	}
+	if req.IsCall() {
+		return true, nil
+	}
+	return nil, nil
+}
+
+// touch records that something was read from the peer.
+func (c *Connection) touch() {
+	if c.keepalive != nil {
+		atomic.StoreInt64(&c.lastRead, c.keepalive.Clock.Now().UnixNano())
+	}
+}
+
+// runKeepalive pings the peer until the connection is done, and closes it
+// when the peer stops responding.
+func (c *Connection) runKeepalive(ctx context.Context) {
+	k := c.keepalive
+	c.touch()
+	// the ping calls are awaited so that they are finished, until they are
+	// answered or older than the timeout
+	type ping struct {
+		sent   time.Time
+		cancel func()
+	}
+	var pings []ping
+	defer func() {
+		for _, p := range pings {
+			p.cancel()
+		}
+	}()
+	for {
+		select {
+		case <-c.async.ready:
+			return
+		case <-k.Clock.After(k.Interval):
+		}
+		last := time.Unix(0, atomic.LoadInt64(&c.lastRead))
+		if k.Clock.Now().Sub(last) > k.Timeout {
+			event.Error(ctx, "jsonrpc2 peer stopped responding", ErrPeerTimeout)
+			c.async.setError(ErrPeerTimeout)
+			c.closer.Close()
+			return
+		}
+		if k.Call {
+			now := k.Clock.Now()
+			for len(pings) > 0 && now.Sub(pings[0].sent) > k.Timeout {
+				pings[0].cancel()
+				pings = pings[1:]
+			}
+			// the response is only used to notice the peer is alive
+			pctx, cancel := context.WithCancel(ctx)
+			go c.Call(pctx, k.Method, nil).Await(pctx, nil)
+			pings = append(pings, ping{sent: now, cancel: cancel})
+		} else if err := c.Notify(ctx, k.Method, nil); err != nil {
+			event.Error(ctx, "jsonrpc2 ping failed", err)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// fakeClock is a Clock that only moves when told to.
+type fakeClock struct {
+	waiting chan struct{} // receives a value each time After is called
+
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		waiting: make(chan struct{}, 10),
+		now:     time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), c: ch})
+	c.waiting <- struct{}{}
+	return ch
+}
+
+// tick waits until n goroutines are waiting on the clock, and then moves it
+// forward by d.
+func (c *fakeClock) tick(t *testing.T, n int, d time.Duration) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.waiting:
+		case <-time.After(5 * time.Second):
+			t.Fatal("nothing is waiting on the clock")
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			timers = append(timers, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.timers = timers
+}
+
+// silentPeer accepts a connection and reads everything sent to it without
+// ever answering, like a peer that has gone away, and reports the methods it
+// read.
+func silentPeer(t *testing.T, ctx context.Context, listener jsonrpc2.Listener) <-chan string {
+	methods := make(chan string, 100)
+	go func() {
+		defer close(methods)
+		rwc, err := listener.Accept(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer rwc.Close()
+		reader := jsonrpc2.HeaderFramer().Reader(rwc)
+		for {
+			msg, _, err := reader.Read(ctx)
+			if err != nil {
+				return
+			}
+			if req, ok := msg.(*jsonrpc2.Request); ok {
+				select {
+				case methods <- req.Method:
+				default:
+				}
+			}
+		}
+	}()
+	return methods
+}
+
+func TestKeepaliveDeadPeer(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	methods := silentPeer(t, ctx, listener)
+	clock := newFakeClock()
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{
+		Keepalive: &jsonrpc2.Keepalive{Method: "ping", Interval: time.Second, Timeout: 3 * time.Second, Clock: clock},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	call := client.Call(ctx, "hello", nil)
+	for i := 0; i < 4; i++ {
+		clock.tick(t, 1, time.Second)
+	}
+	if err := client.Wait(); err != jsonrpc2.ErrPeerTimeout {
+		t.Errorf("connection closed with %v, want ErrPeerTimeout", err)
+	}
+	if err := call.Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrPeerTimeout) {
+		t.Errorf("the pending call returned %v, want ErrPeerTimeout", err)
+	}
+	if err := client.Call(ctx, "hello", nil).Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrPeerTimeout) {
+		t.Errorf("a call after the timeout returned %v, want ErrPeerTimeout", err)
+	}
+	var got []string
+	for m := range methods {
+		got = append(got, m)
+	}
+	if len(got) != 4 || got[0] != "hello" || got[1] != "ping" {
+		t.Errorf("the peer read %q, want a call and three pings", got)
+	}
+}
+
+func TestKeepaliveLivePeer(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		client *jsonrpc2.Keepalive
+		server *jsonrpc2.Keepalive
+		clocks int // the number of keepalives waiting on the clock
+	}{
+		{"notifications", &jsonrpc2.Keepalive{}, &jsonrpc2.Keepalive{}, 2},
+		{"calls", &jsonrpc2.Keepalive{Call: true}, &jsonrpc2.Keepalive{Call: true}, 2},
+		// the server does not know the method, but its error is still a response
+		{"unknown method", &jsonrpc2.Keepalive{Method: "heartbeat", Call: true}, nil, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stacktest.NoLeak(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			clock := newFakeClock()
+			options := func(k *jsonrpc2.Keepalive) jsonrpc2.ConnectionOptions {
+				if k != nil {
+					k.Interval = time.Second
+					k.Timeout = 3 * time.Second
+					k.Clock = clock
+				}
+				return jsonrpc2.ConnectionOptions{Handler: fakeHandler{}, Keepalive: k}
+			}
+			listener, err := jsonrpc2.NetPipe(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server, err := jsonrpc2.Serve(ctx, listener, options(test.server))
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := jsonrpc2.Dial(ctx, listener.Dialer(), options(test.client))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				listener.Close()
+				client.Close()
+				server.Wait()
+			}()
+
+			for i := 0; i < 10; i++ {
+				clock.tick(t, test.clocks, time.Second)
+				// let the pings through before the next check
+				if err := client.Call(ctx, "ping", nil).Await(ctx, nil); err != nil {
+					t.Fatalf("call after %d intervals failed: %v", i+1, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx := context.Background()
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	silentPeer(t, ctx, listener)
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{
+		CallTimeout: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Call(ctx, "hello", nil).Await(ctx, nil); err != context.DeadlineExceeded {
+		t.Errorf("Await without a deadline returned %v, want DeadlineExceeded", err)
+	}
+	// a deadline of the caller wins over the default
+	dctx, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	call := client.Call(dctx, "hello", nil)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := call.Await(dctx, nil); err != context.Canceled {
+		t.Errorf("Await with a deadline returned %v, want it cancelled", err)
+	}
+}
+
+// spanCounter is an event handler that counts the spans of the outgoing
+// requests of a method, and those of them that ended.
+type spanCounter struct {
+	method string
+
+	mu    sync.Mutex
+	spans map[uint64]bool // whether each span has ended
+}
+
+func (s *spanCounter) Event(ctx context.Context, ev *event.Event) context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch ev.Kind {
+	case event.StartKind:
+		if ev.Find("name").String() == s.method && ev.Find("direction").String() == jsonrpc2.Outbound {
+			s.spans[ev.ID] = false
+		}
+	case event.EndKind:
+		if _, ok := s.spans[ev.Parent]; ok {
+			s.spans[ev.Parent] = true
+		}
+	}
+	return ctx
+}
+
+func (s *spanCounter) count() (started, ended int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, done := range s.spans {
+		started++
+		if done {
+			ended++
+		}
+	}
+	return started, ended
+}
+
+// TestKeepalivePingSpans checks that the ping calls are finished once they
+// are answered.
+func TestKeepalivePingSpans(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	spans := &spanCounter{method: "$/ping", spans: make(map[uint64]bool)}
+	ctx = event.WithExporter(ctx, event.NewExporter(spans, nil))
+	clock := newFakeClock()
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, jsonrpc2.ConnectionOptions{Handler: fakeHandler{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{
+		Keepalive: &jsonrpc2.Keepalive{Call: true, Interval: time.Second, Clock: clock},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		listener.Close()
+		client.Close()
+		server.Wait()
+	}()
+
+	const pings = 3
+	for i := 0; i < pings; i++ {
+		clock.tick(t, 1, time.Second)
+		// the ping is answered before this call
+		if err := client.Call(ctx, "ping", nil).Await(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		started, ended := spans.count()
+		if started == pings && ended == pings {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d ping spans ended, want %d of %d", ended, started, pings, pings)
+		}
+	}
+}
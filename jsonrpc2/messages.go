@@ -5,6 +5,7 @@
 package jsonrpc2
 
 import (
+	"bytes"
 	"encoding/json"
 
 	errors "golang.org/x/xerrors"
@@ -17,8 +18,8 @@ type ID struct {
 
 // Message is the interface to all jsonrpc2 message types.
 // They share no common functionality, but are a closed set of concrete types
-// that are allowed to implement this interface. The message types are *Request
-// and *Response.
+// that are allowed to implement this interface. The message types are
+// *Request, *Response and Batch.
 type Message interface {
 	// marshal builds the wire form from the API form.
 	// It is private, which makes the set of Message implementations closed.
@@ -48,6 +49,16 @@ type Response struct {
 	ID ID
 }
 
+// Batch is a Message that holds several requests, or the responses to the
+// calls of a batch of requests, which are sent together as a JSON array.
+// A Batch cannot contain another Batch.
+type Batch []Message
+
+var (
+	errEmptyBatch  = errors.New("empty batch")
+	errNestedBatch = errors.New("batch inside a batch")
+)
+
 // StringID creates a new string request identifier.
 func StringID(s string) ID { return ID{value: s} }
 
@@ -92,10 +103,19 @@ func NewResponse(id ID, result interface{}, rerr error) (*Response, error) {
 
 func (msg *Response) marshal(to *wireCombined) {
 	to.ID = msg.ID.value
+	if to.ID == nil {
+		// a response always has an id, which is null if the id of the request
+		// could not be read
+		to.ID = json.RawMessage("null")
+	}
 	to.Error = toWireError(msg.Error)
 	to.Result = msg.Result
 }
 
+// marshal is never called for a Batch, which is not a single object on the
+// wire; EncodeMessage handles it.
+func (msg Batch) marshal(to *wireCombined) {}
+
 func toWireError(err error) *wireError {
 	if err == nil {
 		// no error, the response is complete
@@ -116,6 +136,9 @@ func toWireError(err error) *wireError {
 }
 
 func EncodeMessage(msg Message) ([]byte, error) {
+	if batch, ok := msg.(Batch); ok {
+		return encodeBatch(batch)
+	}
 	wire := wireCombined{VersionTag: wireVersion}
 	msg.marshal(&wire)
 	data, err := json.Marshal(&wire)
@@ -125,7 +148,85 @@ func EncodeMessage(msg Message) ([]byte, error) {
 	return data, nil
 }
 
+func encodeBatch(batch Batch) ([]byte, error) {
+	if len(batch) == 0 {
+		return nil, errors.Errorf("marshaling jsonrpc batch: %w", errEmptyBatch)
+	}
+	wires := make([]wireCombined, len(batch))
+	for i, msg := range batch {
+		if _, ok := msg.(Batch); ok {
+			return nil, errors.Errorf("marshaling jsonrpc batch: %w", errNestedBatch)
+		}
+		wires[i].VersionTag = wireVersion
+		msg.marshal(&wires[i])
+	}
+	data, err := json.Marshal(wires)
+	if err != nil {
+		return data, errors.Errorf("marshaling jsonrpc batch: %w", err)
+	}
+	return data, nil
+}
+
+// DecodeMessage decodes a single message, or a Batch if data is a JSON array.
+// A batch that is empty or has invalid members is an error, which a
+// Connection answers with ErrInvalidRequest responses.
 func DecodeMessage(data []byte) (Message, error) {
+	if isBatch(data) {
+		return decodeBatch(data)
+	}
+	return decodeSingle(data)
+}
+
+// isBatch reports whether data holds a JSON array.
+func isBatch(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '['
+}
+
+func decodeBatch(data []byte) (Message, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, errors.Errorf("unmarshaling jsonrpc batch: %w", err)
+	}
+	if len(raws) == 0 {
+		return nil, &batchError{empty: true, invalid: []error{
+			errors.Errorf("%w: %v", ErrInvalidRequest, errEmptyBatch),
+		}}
+	}
+	batch := make(Batch, 0, len(raws))
+	var invalid []error
+	for _, raw := range raws {
+		if isBatch(raw) {
+			invalid = append(invalid, errors.Errorf("%w: %v", ErrInvalidRequest, errNestedBatch))
+			continue
+		}
+		msg, err := decodeSingle(raw)
+		if err != nil {
+			invalid = append(invalid, errors.Errorf("%w: %v", ErrInvalidRequest, err))
+			continue
+		}
+		batch = append(batch, msg)
+	}
+	if len(invalid) > 0 {
+		return nil, &batchError{batch: batch, invalid: invalid}
+	}
+	return batch, nil
+}
+
+// batchError is returned by DecodeMessage for a batch that is empty or has
+// invalid members.
+// It holds the members that could be decoded, so that a Connection can still
+// answer the batch as the specification requires.
+type batchError struct {
+	empty   bool    // the batch had no members at all
+	batch   Batch   // the valid members
+	invalid []error // the error for each invalid member
+}
+
+func (e *batchError) Error() string { return e.invalid[0].Error() }
+func (e *batchError) Unwrap() error { return e.invalid[0] }
+
+func decodeSingle(data []byte) (Message, error) {
 	msg := wireCombined{}
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, errors.Errorf("unmarshaling jsonrpc message: %w", err)
@@ -133,18 +234,9 @@ func DecodeMessage(data []byte) (Message, error) {
 	if msg.VersionTag != wireVersion {
 		return nil, errors.Errorf("invalid message version tag %s expected %s", msg.VersionTag, wireVersion)
 	}
-	id := ID{}
-	switch v := msg.ID.(type) {
-	case nil:
-	case float64:
-		// coerce the id type to int64 if it is float64, the spec does not allow fractional parts
-		id = Int64ID(int64(v))
-	case int64:
-		id = Int64ID(v)
-	case string:
-		id = StringID(v)
-	default:
-		return nil, errors.Errorf("invalid message id type <%T>%v", v, v)
+	id, err := makeID(msg.ID)
+	if err != nil {
+		return nil, err
 	}
 	if msg.Method != "" {
 		// has a method, must be a call
@@ -155,7 +247,9 @@ func DecodeMessage(data []byte) (Message, error) {
 		}, nil
 	}
 	// no method, should be a response
-	if !id.IsValid() {
+	if !id.IsValid() && msg.Error == nil {
+		// only an error response can have a null id, when the request it is
+		// a response to could not be read
 		return nil, ErrInvalidRequest
 	}
 	resp := &Response{
@@ -179,3 +273,20 @@ func marshalToRaw(obj interface{}) (json.RawMessage, error) {
 	}
 	return json.RawMessage(data), nil
 }
+
+// makeID builds an ID from its decoded JSON form.
+func makeID(v interface{}) (ID, error) {
+	switch v := v.(type) {
+	case nil:
+		return ID{}, nil
+	case float64:
+		// coerce the id type to int64 if it is float64, the spec does not allow fractional parts
+		return Int64ID(int64(v)), nil
+	case int64:
+		return Int64ID(v), nil
+	case string:
+		return StringID(v), nil
+	default:
+		return ID{}, errors.Errorf("invalid message id type <%T>%v", v, v)
+	}
+}
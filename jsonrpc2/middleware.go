@@ -0,0 +1,131 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"golang.org/x/exp/event"
+	errors "golang.org/x/xerrors"
+)
+
+// Middleware wraps a Handler to add behavior around the handling of requests.
+// Middleware should return ErrNotHandled and ErrAsyncResponse from the
+// wrapped Handler unchanged, so that it can also wrap a Preempter.
+type Middleware func(Handler) Handler
+
+// Chain returns h wrapped in the middleware, the first of which sees each
+// request first.
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// ChainPreempter returns p wrapped in the middleware, the first of which sees
+// each request first.
+func ChainPreempter(p Preempter, middleware ...Middleware) Preempter {
+	h := Chain(HandlerFunc(p.Preempt), middleware...)
+	return PreempterFunc(h.Handle)
+}
+
+// PreempterFunc is an adapter to allow the use of ordinary functions as
+// preempters, as ChainPreempter does with the chained handler.
+type PreempterFunc func(ctx context.Context, req *Request) (interface{}, error)
+
+func (f PreempterFunc) Preempt(ctx context.Context, req *Request) (interface{}, error) {
+	return f(ctx, req)
+}
+
+// Recover returns middleware that turns a panic in the handler into an
+// ErrInternal error for the request. The panic is logged as an error event
+// with the stack of the panicking goroutine.
+func Recover() Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (result interface{}, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = errors.Errorf("%w: panic in %q: %v", ErrInternal, req.Method, r)
+					result = nil
+					event.Error(ctx, "jsonrpc2 handler panic", err,
+						Method(req.Method), event.String("stack", string(debug.Stack())))
+				}
+			}()
+			return h.Handle(ctx, req)
+		})
+	}
+}
+
+// Timeouts returns middleware that gives each request a deadline, from the
+// timeout for its method in perMethod, or timeout if it has none. A timeout
+// of zero means no deadline.
+// The deadline ends when the handler returns, so Timeouts should not wrap
+// handlers that respond asynchronously.
+func Timeouts(timeout time.Duration, perMethod map[string]time.Duration) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (interface{}, error) {
+			d, ok := perMethod[req.Method]
+			if !ok {
+				d = timeout
+			}
+			if d <= 0 {
+				return h.Handle(ctx, req)
+			}
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return h.Handle(ctx, req)
+		})
+	}
+}
+
+// MaxParamsSize returns middleware that rejects requests whose params are
+// longer than n bytes with ErrInvalidParams.
+func MaxParamsSize(n int) Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (interface{}, error) {
+			if len(req.Params) > n {
+				return nil, errors.Errorf("%w: %q params are %d bytes, more than %d", ErrInvalidParams, req.Method, len(req.Params), n)
+			}
+			return h.Handle(ctx, req)
+		})
+	}
+}
+
+// AccessLog returns middleware that logs an event for each request it
+// handles, with the Method, RPCID and StatusCode labels, the time it took, and
+// the error if there is one.
+// Requests that are not handled are not logged.
+func AccessLog() Middleware {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, req *Request) (interface{}, error) {
+			start := time.Now()
+			result, err := h.Handle(ctx, req)
+			if err == ErrNotHandled {
+				return result, err
+			}
+			labels := []event.Label{
+				Method(req.Method),
+				event.Duration("elapsed", time.Since(start)),
+			}
+			if req.IsCall() {
+				labels = append(labels, RPCID(fmt.Sprintf("%q", req.ID)))
+			}
+			switch err {
+			case nil:
+				labels = append(labels, StatusCode("OK"))
+			case ErrAsyncResponse:
+				labels = append(labels, StatusCode("ASYNC"))
+			default:
+				labels = append(labels, StatusCode("ERROR"), event.Value("error", err))
+			}
+			event.Log(ctx, "jsonrpc2 request", labels...)
+			return result, err
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/event"
+	"golang.org/x/exp/event/eventtest"
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// named returns middleware that records its name in log on the way in and
+// out of the handler.
+func named(log *[]string, name string) jsonrpc2.Middleware {
+	return func(h jsonrpc2.Handler) jsonrpc2.Handler {
+		return jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+			*log = append(*log, "in "+name)
+			defer func() { *log = append(*log, "out "+name) }()
+			return h.Handle(ctx, req)
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var log []string
+	inner := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+		log = append(log, "handle")
+		return nil, jsonrpc2.ErrNotHandled
+	})
+	req, _ := jsonrpc2.NewNotification("m", nil)
+	want := "in a,in b,handle,out b,out a"
+
+	h := jsonrpc2.Chain(inner, named(&log, "a"), named(&log, "b"))
+	if _, err := h.Handle(context.Background(), req); err != jsonrpc2.ErrNotHandled {
+		t.Errorf("Handle returned %v, want ErrNotHandled", err)
+	}
+	if got := strings.Join(log, ","); got != want {
+		t.Errorf("handler chain ran %q, want %q", got, want)
+	}
+
+	log = nil
+	p := jsonrpc2.ChainPreempter(jsonrpc2.PreempterFunc(inner), named(&log, "a"), named(&log, "b"))
+	if _, err := p.Preempt(context.Background(), req); err != jsonrpc2.ErrNotHandled {
+		t.Errorf("Preempt returned %v, want ErrNotHandled", err)
+	}
+	if got := strings.Join(log, ","); got != want {
+		t.Errorf("preempter chain ran %q, want %q", got, want)
+	}
+}
+
+func TestMiddlewareErrors(t *testing.T) {
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+		switch req.Method {
+		case "panic":
+			panic("boom")
+		case "deadline":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		default:
+			return "ok", nil
+		}
+	})
+	h := jsonrpc2.Chain(handler,
+		jsonrpc2.Recover(),
+		jsonrpc2.MaxParamsSize(10),
+		jsonrpc2.Timeouts(0, map[string]time.Duration{"deadline": 10 * time.Millisecond}),
+	)
+	for _, test := range []struct {
+		method  string
+		params  interface{}
+		wantErr error
+	}{
+		{"echo", "short", nil},
+		{"echo", "much too long", jsonrpc2.ErrInvalidParams},
+		{"panic", nil, jsonrpc2.ErrInternal},
+		{"deadline", nil, context.DeadlineExceeded},
+	} {
+		req, err := jsonrpc2.NewCall(jsonrpc2.Int64ID(1), test.method, test.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = h.Handle(context.Background(), req)
+		if !errors.Is(err, test.wantErr) || (err == nil) != (test.wantErr == nil) {
+			t.Errorf("%s(%v) returned %v, want %v", test.method, test.params, err, test.wantErr)
+		}
+	}
+}
+
+func TestRecoverOverWire(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+		panic("boom")
+	})
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, jsonrpc2.ConnectionOptions{
+		Handler: jsonrpc2.Chain(handler, jsonrpc2.Recover()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		listener.Close()
+		client.Close()
+		server.Wait()
+	}()
+	err = client.Call(ctx, "explode", nil).Await(ctx, nil)
+	if !errors.Is(err, jsonrpc2.ErrInternal) || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("got %v, want an ErrInternal for the panic", err)
+	}
+	// the server survived the panic
+	err = client.Call(ctx, "explode", nil).Await(ctx, nil)
+	if !errors.Is(err, jsonrpc2.ErrInternal) {
+		t.Errorf("got %v after the first panic, want ErrInternal", err)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	ctx, capture := eventtest.NewCapture()
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+		switch req.Method {
+		case "fail":
+			return nil, jsonrpc2.ErrInvalidParams
+		case "skip":
+			return nil, jsonrpc2.ErrNotHandled
+		default:
+			return json.RawMessage("true"), nil
+		}
+	})
+	h := jsonrpc2.Chain(handler, jsonrpc2.AccessLog())
+	for _, test := range []struct {
+		method string
+		call   bool
+		status string // empty if not logged
+	}{
+		{"ok", true, "OK"},
+		{"fail", true, "ERROR"},
+		{"note", false, "OK"},
+		{"skip", true, ""},
+	} {
+		capture.Reset()
+		var req *jsonrpc2.Request
+		if test.call {
+			req, _ = jsonrpc2.NewCall(jsonrpc2.Int64ID(7), test.method, nil)
+		} else {
+			req, _ = jsonrpc2.NewNotification(test.method, nil)
+		}
+		h.Handle(ctx, req)
+		if test.status == "" {
+			if len(capture.Got) != 0 {
+				t.Errorf("%s: got %d events, want none", test.method, len(capture.Got))
+			}
+			continue
+		}
+		if len(capture.Got) != 1 {
+			t.Fatalf("%s: got %d events, want 1", test.method, len(capture.Got))
+		}
+		ev := &capture.Got[0]
+		if ev.Kind != event.LogKind {
+			t.Errorf("%s: got a %v event, want a log", test.method, ev.Kind)
+		}
+		if got := ev.Find("method").String(); got != test.method {
+			t.Errorf("%s: method label is %q", test.method, got)
+		}
+		if got := ev.Find("status.code").String(); got != test.status {
+			t.Errorf("%s: status label is %q, want %q", test.method, got, test.status)
+		}
+		// the same id label as the span of the request
+		if id := ev.Find("id"); id.HasValue() != test.call || (test.call && id.String() != fmt.Sprintf("%q", req.ID)) {
+			t.Errorf("%s: id label is %v", test.method, id)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	errors "golang.org/x/xerrors"
+)
+
+// Mux is a Handler and Preempter that routes requests by method to functions
+// registered with Register and RegisterPreempt, decoding their params.
+//
+// Params that are a JSON object are decoded into the params type by name,
+// with the usual rules of encoding/json. Params that are a JSON array are
+// decoded by position: into the fields of the params type, in order, if it
+// is a struct, and with encoding/json otherwise. Missing params leave the
+// params at their zero value.
+// Params that cannot be decoded are reported as ErrInvalidParams.
+//
+// The result of a function is discarded for notifications.
+type Mux struct {
+	mu      sync.RWMutex
+	handle  map[string]muxFunc
+	preempt map[string]muxFunc
+}
+
+type muxFunc func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// NewMux returns a Mux with no methods.
+func NewMux() *Mux {
+	return &Mux{
+		handle:  make(map[string]muxFunc),
+		preempt: make(map[string]muxFunc),
+	}
+}
+
+// Register makes m handle requests for method with f, which is called from
+// the Handler, in the order the connection allows.
+// Registering the same method again replaces f.
+func Register[P, R any](m *Mux, method string, f func(context.Context, P) (R, error)) {
+	m.register(m.handle, method, wrap(f))
+}
+
+// RegisterPreempt makes m handle requests for method with f, which is called
+// from the Preempter, before the request is queued. Like a Preempter, f must
+// not block.
+// Registering the same method again replaces f.
+func RegisterPreempt[P, R any](m *Mux, method string, f func(context.Context, P) (R, error)) {
+	m.register(m.preempt, method, wrap(f))
+}
+
+func (m *Mux) register(table map[string]muxFunc, method string, f muxFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	table[method] = f
+}
+
+// Preempt calls the function registered for the method with RegisterPreempt,
+// if any, and returns ErrNotHandled otherwise.
+func (m *Mux) Preempt(ctx context.Context, req *Request) (interface{}, error) {
+	m.mu.RLock()
+	f := m.preempt[req.Method]
+	m.mu.RUnlock()
+	if f == nil {
+		return nil, ErrNotHandled
+	}
+	return call(ctx, f, req)
+}
+
+// Handle calls the function registered for the method with Register, and
+// returns ErrMethodNotFound if there is none.
+func (m *Mux) Handle(ctx context.Context, req *Request) (interface{}, error) {
+	m.mu.RLock()
+	f := m.handle[req.Method]
+	m.mu.RUnlock()
+	if f == nil {
+		return nil, errors.Errorf("%w: %q", ErrMethodNotFound, req.Method)
+	}
+	return call(ctx, f, req)
+}
+
+func call(ctx context.Context, f muxFunc, req *Request) (interface{}, error) {
+	result, err := f(ctx, req.Params)
+	if !req.IsCall() {
+		return nil, err
+	}
+	return result, err
+}
+
+// wrap turns a typed function into one that decodes its params.
+func wrap[P, R any](f func(context.Context, P) (R, error)) muxFunc {
+	return func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		var params P
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, errors.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+		return f(ctx, params)
+	}
+}
+
+// decodeParams decodes raw into the value pointed to by v, by name or by
+// position.
+func decodeParams(raw json.RawMessage, v interface{}) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+	target := reflect.ValueOf(v).Elem()
+	for target.Kind() == reflect.Ptr {
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		target = target.Elem()
+	}
+	if raw[0] != '[' || target.Kind() != reflect.Struct {
+		return json.Unmarshal(raw, v)
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return err
+	}
+	fields := positionalFields(target.Type())
+	if len(elems) > len(fields) {
+		return errors.Errorf("got %d params, want at most %d", len(elems), len(fields))
+	}
+	for i, elem := range elems {
+		if err := json.Unmarshal(elem, fieldByIndex(target, fields[i]).Addr().Interface()); err != nil {
+			return errors.Errorf("param %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// positionalFields returns the indexes of the fields of a struct type that are
+// filled by position, which are the fields encoding/json would decode, in
+// declaration order.
+// As for encoding/json, the fields of embedded structs are promoted in place,
+// and a name is only decoded into the shallowest field that has it, so a
+// field hidden by another, or one of several with the same name and depth, is
+// not filled.
+func positionalFields(t reflect.Type) [][]int {
+	type field struct {
+		index  []int
+		name   string
+		tagged bool
+	}
+	var all []field
+	var walk func(t reflect.Type, index []int, path map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, path map[reflect.Type]bool) {
+		path[t] = true
+		defer delete(path, t)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			ft := f.Type
+			if f.Anonymous && ft.Kind() == reflect.Ptr {
+				if !f.IsExported() {
+					// encoding/json cannot allocate it
+					continue
+				}
+				ft = ft.Elem()
+			}
+			fieldIndex := append(index[:len(index):len(index)], i)
+			if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				if !path[ft] {
+					walk(ft, fieldIndex, path)
+				}
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			tagged := name != ""
+			if !tagged {
+				name = f.Name
+			}
+			all = append(all, field{index: fieldIndex, name: name, tagged: tagged})
+		}
+	}
+	walk(t, nil, map[reflect.Type]bool{})
+
+	// keep the dominant field for each name: the only shallowest one, or the
+	// only tagged one among them
+	type dominant struct {
+		depth, count, tagged int
+		field                []int
+	}
+	byName := map[string]*dominant{}
+	for _, f := range all {
+		d := byName[f.name]
+		if d == nil || len(f.index) < d.depth {
+			d = &dominant{depth: len(f.index)}
+			byName[f.name] = d
+		}
+		if len(f.index) > d.depth {
+			continue
+		}
+		d.count++
+		if f.tagged {
+			d.tagged++
+		}
+		if d.count == 1 || (f.tagged && d.tagged == 1) {
+			d.field = f.index
+		}
+	}
+	var fields [][]int
+	for _, f := range all {
+		d := byName[f.name]
+		if (d.count == 1 || d.tagged == 1) && reflect.DeepEqual(d.field, f.index) {
+			fields = append(fields, f.index)
+		}
+	}
+	return fields
+}
+
+// fieldByIndex returns the nested field of v with the given index, allocating
+// the embedded pointers on the way to it.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// Invoke calls method on the connection with params, and waits for its result.
+// It is the typed counterpart of functions registered on a Mux; the result
+// type comes first so that it can be given while the params type is inferred,
+// as in Invoke[int](ctx, conn, "add", args).
+func Invoke[R, P any](ctx context.Context, c *Connection, method string, params P) (R, error) {
+	var result R
+	err := c.Call(ctx, method, params).Await(ctx, &result)
+	return result, err
+}
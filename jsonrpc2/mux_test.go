@@ -0,0 +1,158 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+type addParams struct {
+	A, B int
+}
+
+type greetParams struct {
+	Name     string `json:"name"`
+	Greeting string `json:"greeting,omitempty"`
+	Ignored  int    `json:"-"`
+}
+
+type point struct{ X, Y int }
+
+type Size struct{ W, H int }
+
+// boxParams has its fields promoted from embedded structs, and the X of point
+// hidden by its own.
+type boxParams struct {
+	point
+	*Size
+	X    int
+	Name string
+}
+
+func newTestMux() *jsonrpc2.Mux {
+	mux := jsonrpc2.NewMux()
+	jsonrpc2.Register(mux, "add", func(ctx context.Context, p addParams) (int, error) {
+		return p.A + p.B, nil
+	})
+	jsonrpc2.Register(mux, "greet", func(ctx context.Context, p *greetParams) (string, error) {
+		if p.Greeting == "" {
+			p.Greeting = "hello"
+		}
+		return p.Greeting + " " + p.Name, nil
+	})
+	jsonrpc2.Register(mux, "sum", func(ctx context.Context, p []int) (int, error) {
+		total := 0
+		for _, v := range p {
+			total += v
+		}
+		return total, nil
+	})
+	jsonrpc2.Register(mux, "box", func(ctx context.Context, p boxParams) (string, error) {
+		if p.Size == nil {
+			p.Size = &Size{}
+		}
+		return fmt.Sprintf("%s %d %d %dx%d %d", p.Name, p.X, p.Y, p.W, p.H, p.point.X), nil
+	})
+	jsonrpc2.Register(mux, "fail", func(ctx context.Context, p struct{}) (bool, error) {
+		return false, jsonrpc2.NewError(42, "failed on purpose")
+	})
+	jsonrpc2.RegisterPreempt(mux, "upper", func(ctx context.Context, s string) (string, error) {
+		return strings.ToUpper(s), nil
+	})
+	return mux
+}
+
+func TestMux(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	mux := newTestMux()
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, jsonrpc2.ConnectionOptions{
+		Handler:   mux,
+		Preempter: mux,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		listener.Close()
+		client.Close()
+		server.Wait()
+	}()
+
+	for _, test := range []struct {
+		name    string
+		method  string
+		params  interface{}
+		want    string // the JSON of the result
+		wantErr error
+	}{
+		{"by name", "add", map[string]int{"A": 1, "B": 2}, "3", nil},
+		{"by position", "add", []int{3, 4}, "7", nil},
+		{"missing position", "add", []int{3}, "3", nil},
+		{"no params", "add", nil, "0", nil},
+		{"pointer params", "greet", greetParams{Name: "gopher"}, `"hello gopher"`, nil},
+		{"position skips ignored fields", "greet", []string{"gopher", "hi"}, `"hi gopher"`, nil},
+		{"embedded by name", "box", map[string]interface{}{"Y": 2, "W": 3, "H": 4, "X": 1, "Name": "b"}, `"b 1 2 3x4 0"`, nil},
+		{"embedded by position", "box", []interface{}{2, 3, 4, 1, "b"}, `"b 1 2 3x4 0"`, nil},
+		{"embedded partly", "box", []interface{}{2}, `" 0 2 0x0 0"`, nil},
+		{"slice params", "sum", []int{1, 2, 3}, "6", nil},
+		{"preempted", "upper", "abc", `"ABC"`, nil},
+		{"too many positions", "add", []int{1, 2, 3}, "", jsonrpc2.ErrInvalidParams},
+		{"wrong type by name", "add", map[string]string{"A": "x"}, "", jsonrpc2.ErrInvalidParams},
+		{"wrong type by position", "add", []string{"x"}, "", jsonrpc2.ErrInvalidParams},
+		{"wrong kind", "sum", map[string]int{}, "", jsonrpc2.ErrInvalidParams},
+		{"unknown method", "missing", nil, "", jsonrpc2.ErrMethodNotFound},
+		{"handler error", "fail", nil, "", jsonrpc2.NewError(42, "")},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got json.RawMessage
+			err := client.Call(ctx, test.method, test.params).Await(ctx, &got)
+			switch {
+			case test.wantErr != nil:
+				if !errors.Is(err, test.wantErr) {
+					t.Errorf("got error %v, want %v", err, test.wantErr)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case string(got) != test.want:
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	// the typed client helper
+	sum, err := jsonrpc2.Invoke[int](ctx, client, "add", addParams{A: 20, B: 22})
+	if err != nil || sum != 42 {
+		t.Errorf("Invoke returned %v, %v; want 42", sum, err)
+	}
+	if _, err := jsonrpc2.Invoke[int](ctx, client, "add", "x"); !errors.Is(err, jsonrpc2.ErrInvalidParams) {
+		t.Errorf("Invoke with bad params returned %v; want ErrInvalidParams", err)
+	}
+	// results of notifications are dropped
+	if err := client.Notify(ctx, "add", []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(ctx, "add", nil).Await(ctx, nil); err != nil {
+		t.Fatalf("the connection failed after a notification: %v", err)
+	}
+}
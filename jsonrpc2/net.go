@@ -9,6 +9,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -93,8 +94,9 @@ func NetPipe(ctx context.Context) (Listener, error) {
 
 // netPiper is the implementation of Listener build on top of net.Pipes.
 type netPiper struct {
-	done   chan struct{}
-	dialed chan io.ReadWriteCloser
+	done      chan struct{}
+	dialed    chan io.ReadWriteCloser
+	closeOnce sync.Once
 }
 
 // Accept blocks waiting for an incoming connection to the listener.
@@ -114,7 +116,7 @@ func (l *netPiper) Accept(ctx context.Context) (io.ReadWriteCloser, error) {
 // already been accepted.
 func (l *netPiper) Close() error {
 	// unblock any accept calls that are pending
-	close(l.done)
+	l.closeOnce.Do(func() { close(l.done) })
 	return nil
 }
 
@@ -0,0 +1,265 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// readCounter is a preempter that counts the requests taken off the stream.
+type readCounter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (rc *readCounter) Preempt(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+	rc.mu.Lock()
+	rc.n++
+	rc.mu.Unlock()
+	return nil, jsonrpc2.ErrNotHandled
+}
+
+func (rc *readCounter) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.n
+}
+
+// startQueue starts a server with a tracker and a readCounter, and waits until
+// a "block" call from the returned client is being handled, so that the queue
+// is empty.
+func startQueue(t *testing.T, ctx context.Context, opts jsonrpc2.ConnectionOptions) (*tracker, *readCounter, *jsonrpc2.Connection, *jsonrpc2.AsyncCall, func()) {
+	tr := &tracker{release: make(chan struct{})}
+	rc := &readCounter{}
+	opts.Handler = tr
+	opts.Preempter = rc
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked := client.Call(ctx, "block", "blocked")
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		tr.mu.Lock()
+		running := tr.running
+		tr.mu.Unlock()
+		if running == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the blocking call never started")
+		}
+	}
+	return tr, rc, client, blocked, func() {
+		listener.Close()
+		client.Close()
+		server.Wait()
+	}
+}
+
+func TestQueueReject(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts jsonrpc2.ConnectionOptions
+	}{
+		{"length", jsonrpc2.ConnectionOptions{MaxQueued: 2}},
+		// each queued call is 5 bytes of method and 4 of params
+		{"bytes", jsonrpc2.ConnectionOptions{MaxQueuedBytes: 20}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stacktest.NoLeak(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			test.opts.Overload = jsonrpc2.RejectOverload
+			tr, _, client, blocked, shutdown := startQueue(t, ctx, test.opts)
+			defer shutdown()
+
+			var calls []*jsonrpc2.AsyncCall
+			for i := 0; i < 6; i++ {
+				calls = append(calls, client.Call(ctx, "sleep", fmt.Sprintf("c%d", i)))
+			}
+			// the rejections do not wait for the queue to drain
+			for _, call := range calls[2:] {
+				err := call.Await(ctx, nil)
+				if !errors.Is(err, jsonrpc2.ErrServerOverloaded) {
+					t.Errorf("got %v, want ErrServerOverloaded", err)
+				}
+			}
+			close(tr.release)
+			awaitAll(t, ctx, append(calls[:2], blocked))
+		})
+	}
+}
+
+func TestQueueBackpressure(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tr, rc, client, blocked, shutdown := startQueue(t, ctx, jsonrpc2.ConnectionOptions{MaxQueued: 2})
+	defer shutdown()
+
+	const flood = 20
+	notified := make(chan error, 1)
+	go func() {
+		for i := 0; i < flood; i++ {
+			if err := client.Notify(ctx, "sleep", fmt.Sprintf("n%d", i)); err != nil {
+				notified <- err
+				return
+			}
+		}
+		notified <- nil
+	}()
+	// the blocked call and a full queue, the rest is held back
+	time.Sleep(50 * time.Millisecond)
+	if got := rc.count(); got != 3 {
+		t.Errorf("%d requests were read with a full queue, want 3", got)
+	}
+	close(tr.release)
+	if err := <-notified; err != nil {
+		t.Fatal(err)
+	}
+	awaitAll(t, ctx, []*jsonrpc2.AsyncCall{blocked})
+	// a call after the flood is answered once all the notifications are handled
+	if err := client.Call(ctx, "sleep", "last").Await(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := rc.count(); got != flood+2 {
+		t.Errorf("%d requests were read, want %d", got, flood+2)
+	}
+}
+
+// TestQueueFlood checks that a peer flooding a full queue with notifications
+// is held back, rather than having them buffered without limit.
+func TestQueueFlood(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		overload jsonrpc2.OverloadPolicy
+	}{
+		{"backpressure", jsonrpc2.Backpressure},
+		// notifications are queued anyway, but only up to a point
+		{"reject", jsonrpc2.RejectOverload},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stacktest.NoLeak(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			tr, rc, client, blocked, shutdown := startQueue(t, ctx, jsonrpc2.ConnectionOptions{
+				MaxQueued: 2,
+				Overload:  test.overload,
+			})
+			defer shutdown()
+
+			const flood = 30
+			sent := make(chan int, flood)
+			go func() {
+				defer close(sent)
+				for i := 0; i < flood; i++ {
+					if err := client.Notify(ctx, "sleep", fmt.Sprintf("n%d", i)); err != nil {
+						t.Error(err)
+						return
+					}
+					sent <- i
+				}
+			}()
+			time.Sleep(50 * time.Millisecond)
+			if n := len(sent); n >= 10 {
+				t.Errorf("%d notifications were written to a full queue, want the writer held back", n)
+			}
+			close(tr.release)
+			for range sent {
+			}
+			awaitAll(t, ctx, []*jsonrpc2.AsyncCall{blocked})
+			for deadline := time.Now().Add(time.Second); rc.count() < flood+1; time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatalf("%d requests were read, want %d", rc.count(), flood+1)
+				}
+			}
+		})
+	}
+}
+
+// TestQueueCallback checks that a handler can call back into the peer while
+// the queue is full, as the response is read despite the backpressure.
+func TestQueueCallback(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, callbackBinder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+	// the client answers the callback only once the server queue is full
+	notified := make(chan struct{})
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{
+		Handler: jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+			<-notified
+			return "pong", nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	call := client.Call(ctx, "callback", nil)
+	go func() {
+		defer close(notified)
+		// one request fills the queue, and one more is held back
+		for i := 0; i < 2; i++ {
+			if err := client.Notify(ctx, "fill", i); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	var result string
+	if err := call.Await(ctx, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result != "pong" {
+		t.Errorf("got %q, want pong", result)
+	}
+}
+
+// callbackBinder binds connections with a queue of one request, whose
+// handler answers "callback" by calling "ping" on the peer.
+type callbackBinder struct{}
+
+func (callbackBinder) Bind(ctx context.Context, conn *jsonrpc2.Connection) (jsonrpc2.ConnectionOptions, error) {
+	return jsonrpc2.ConnectionOptions{
+		MaxQueued: 1,
+		Handler: jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+			if req.Method != "callback" {
+				return nil, nil
+			}
+			var result string
+			err := conn.Call(ctx, "ping", nil).Await(ctx, &result)
+			return result, err
+		}),
+	}, nil
+}
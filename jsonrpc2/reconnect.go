@@ -0,0 +1,251 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/event"
+	errors "golang.org/x/xerrors"
+)
+
+// ErrClientClosed is returned by the methods of a Client after Close.
+var ErrClientClosed = errors.New("jsonrpc2: client closed")
+
+// ClientOptions holds the options for a Client.
+type ClientOptions struct {
+	// MinBackoff is the delay before dialing again after the connection
+	// failed, which doubles with each failed attempt up to MaxBackoff.
+	// If zero, 100 milliseconds and 30 seconds are used.
+	MinBackoff, MaxBackoff time.Duration
+	// MaxAttempts is the number of failed attempts to dial in a row after
+	// which the client gives up and fails all calls. Zero means no limit.
+	MaxAttempts int
+	// OnConnect, if not nil, is called with each new connection before it is
+	// used, to repeat the calls that set up the session, such as handshakes
+	// and subscriptions. If it fails, the connection is closed and dialed
+	// again.
+	OnConnect func(ctx context.Context, conn *Connection) error
+	// Retry reports whether a request whose connection failed before it was
+	// answered may be sent again on the next connection, which should only be
+	// true for idempotent methods.
+	// If nil, such requests fail as soon as the connection does.
+	// See RetryMethods for a simple policy.
+	Retry func(*Request) bool
+	// Clock, if not nil, replaces the system clock for the backoff.
+	Clock Clock
+}
+
+// RetryMethods returns a function for ClientOptions.Retry that retries
+// requests for the given methods.
+func RetryMethods(methods ...string) func(*Request) bool {
+	return methodSet(methods)
+}
+
+// Client is a connection to a server that is dialed again, with exponential
+// backoff, whenever it fails.
+// Calls made while the client is reconnecting wait for the next connection.
+type Client struct {
+	dialer Dialer
+	binder Binder
+	opts   ClientOptions
+	cancel func()        // stops reconnecting
+	done   chan struct{} // closed when the client stops reconnecting
+
+	mu      sync.Mutex
+	conn    *Connection   // the live connection, nil while reconnecting
+	err     error         // set once the client gives up
+	changed chan struct{} // closed and replaced whenever conn or err changes
+}
+
+// NewClient dials a server and returns a client that redials it when the
+// connection fails. The connections are built with the binder, as with Dial.
+// If the first attempt to connect fails, NewClient returns its error.
+// The client closes when ctx is done, as well as on Close.
+func NewClient(ctx context.Context, dialer Dialer, binder Binder, opts ClientOptions) (*Client, error) {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = opts.MinBackoff
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	c := &Client{
+		dialer:  dialer,
+		binder:  binder,
+		opts:    opts,
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	ctx, c.cancel = context.WithCancel(ctx)
+	go c.run(ctx, conn)
+	return c, nil
+}
+
+// connect dials a new connection and sets it up.
+func (c *Client) connect(ctx context.Context) (*Connection, error) {
+	conn, err := Dial(ctx, c.dialer, c.binder)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.OnConnect != nil {
+		if err := c.opts.OnConnect(ctx, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// run waits for each connection to fail, and replaces it.
+func (c *Client) run(ctx context.Context, conn *Connection) {
+	defer close(c.done)
+	for {
+		select {
+		case <-conn.async.ready:
+		case <-ctx.Done():
+			conn.Close()
+			c.set(nil, ErrClientClosed)
+			return
+		}
+		c.set(nil, nil)
+		var err error
+		conn, err = c.reconnect(ctx)
+		if err != nil {
+			c.set(nil, err)
+			return
+		}
+		c.set(conn, nil)
+	}
+}
+
+// reconnect dials until it succeeds, backing off between the attempts.
+func (c *Client) reconnect(ctx context.Context) (*Connection, error) {
+	delay := c.opts.MinBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.opts.Clock.After(delay):
+		case <-ctx.Done():
+			return nil, ErrClientClosed
+		}
+		conn, err := c.connect(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ErrClientClosed
+		}
+		event.Error(ctx, "jsonrpc2 reconnect failed", err, event.Int64("attempt", int64(attempt)))
+		if c.opts.MaxAttempts > 0 && attempt >= c.opts.MaxAttempts {
+			return nil, errors.Errorf("jsonrpc2: giving up after %d attempts to reconnect: %w", attempt, err)
+		}
+		if delay *= 2; delay > c.opts.MaxBackoff {
+			delay = c.opts.MaxBackoff
+		}
+	}
+}
+
+func (c *Client) set(conn *Connection, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn = conn
+	c.err = err
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Connection returns the live connection, waiting for the client to reconnect
+// if needed.
+func (c *Client) Connection(ctx context.Context) (*Connection, error) {
+	return c.connection(ctx, nil)
+}
+
+// connection returns a live connection other than dead.
+func (c *Client) connection(ctx context.Context, dead *Connection) (*Connection, error) {
+	for {
+		c.mu.Lock()
+		conn, err, changed := c.conn, c.err, c.changed
+		c.mu.Unlock()
+		switch {
+		case err != nil:
+			return nil, err
+		case conn != nil && conn != dead:
+			return conn, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Call invokes the method on the server and waits for its result, which is
+// unmarshaled into result as with AsyncCall.Await.
+// If the connection fails before the response arrives, the call either
+// fails, or is sent again on the next connection, according to the Retry
+// policy.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	return c.send(ctx, method, params, func(conn *Connection) error {
+		return conn.Call(ctx, method, params).Await(ctx, result)
+	})
+}
+
+// Notify sends a notification to the server.
+// If the connection fails while it is sent, it is sent again on the next
+// connection according to the Retry policy.
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	return c.send(ctx, method, params, func(conn *Connection) error {
+		return conn.Notify(ctx, method, params)
+	})
+}
+
+func (c *Client) send(ctx context.Context, method string, params interface{}, f func(*Connection) error) error {
+	var dead *Connection
+	for {
+		conn, err := c.connection(ctx, dead)
+		if err != nil {
+			return err
+		}
+		err = f(conn)
+		if err == nil || !lostConnection(err) {
+			return err
+		}
+		if c.opts.Retry == nil {
+			return err
+		}
+		if req, merr := NewNotification(method, params); merr != nil || !c.opts.Retry(req) {
+			return err
+		}
+		dead = conn
+	}
+}
+
+// lostConnection reports whether err means the request was lost with its
+// connection.
+func lostConnection(err error) bool {
+	var closed *connClosedError
+	return errors.As(err, &closed) || isClosingError(err)
+}
+
+// Close stops reconnecting, and closes the connection.
+// Calls waiting for a connection fail with ErrClientClosed.
+func (c *Client) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// killableListener remembers the streams it accepts, so that tests can break
+// them.
+type killableListener struct {
+	jsonrpc2.Listener
+
+	mu      sync.Mutex
+	streams []io.ReadWriteCloser
+}
+
+func (l *killableListener) Accept(ctx context.Context) (io.ReadWriteCloser, error) {
+	rwc, err := l.Listener.Accept(ctx)
+	if err == nil {
+		l.mu.Lock()
+		l.streams = append(l.streams, rwc)
+		l.mu.Unlock()
+	}
+	return rwc, err
+}
+
+// kill closes the server end of all the streams.
+func (l *killableListener) kill() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rwc := range l.streams {
+		rwc.Close()
+	}
+	l.streams = nil
+}
+
+// flakyDialer fails a number of dials, once asked to.
+type flakyDialer struct {
+	jsonrpc2.Dialer
+
+	mu       sync.Mutex
+	failures int // the number of dials left to fail
+	dials    int
+}
+
+func (d *flakyDialer) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	d.mu.Lock()
+	d.dials++
+	fail := d.failures > 0
+	if fail {
+		d.failures--
+	}
+	d.mu.Unlock()
+	if fail {
+		return nil, errors.New("dial failed")
+	}
+	return d.Dialer.Dial(ctx)
+}
+
+// reconnectServer counts the calls to its methods. The first "slow" call
+// blocks until the server stops.
+type reconnectServer struct {
+	listener *killableListener
+	dialer   *flakyDialer
+	started  chan struct{} // a slow call has started
+	release  chan struct{}
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (s *reconnectServer) count(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[method]
+}
+
+func (s *reconnectServer) Handle(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+	s.mu.Lock()
+	s.counts[req.Method]++
+	n := s.counts[req.Method]
+	s.mu.Unlock()
+	switch req.Method {
+	case "init":
+		return true, nil
+	case "echo":
+		return req.Params, nil
+	case "slow":
+		if n == 1 {
+			s.started <- struct{}{}
+			<-s.release
+		}
+		return n, nil
+	default:
+		return nil, jsonrpc2.ErrNotHandled
+	}
+}
+
+func startReconnect(t *testing.T, ctx context.Context, opts jsonrpc2.ClientOptions) (*reconnectServer, *jsonrpc2.Client, func()) {
+	pipe, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &reconnectServer{
+		listener: &killableListener{Listener: pipe},
+		dialer:   &flakyDialer{Dialer: pipe.Dialer()},
+		started:  make(chan struct{}, 1),
+		release:  make(chan struct{}),
+		counts:   make(map[string]int),
+	}
+	server, err := jsonrpc2.Serve(ctx, s.listener, jsonrpc2.ConnectionOptions{Handler: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Millisecond
+	}
+	opts.OnConnect = func(ctx context.Context, conn *jsonrpc2.Connection) error {
+		return conn.Call(ctx, "init", nil).Await(ctx, nil)
+	}
+	client, err := jsonrpc2.NewClient(ctx, s.dialer, jsonrpc2.ConnectionOptions{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, client, func() {
+		client.Close()
+		close(s.release)
+		s.listener.Close()
+		server.Wait()
+	}
+}
+
+// reconnected waits until the client has replaced the connection old.
+func reconnected(t *testing.T, ctx context.Context, client *jsonrpc2.Client, old *jsonrpc2.Connection) {
+	for {
+		conn, err := client.Connection(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn != old {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s, client, shutdown := startReconnect(t, ctx, jsonrpc2.ClientOptions{})
+	defer shutdown()
+
+	for i := 0; i < 3; i++ {
+		old, err := client.Connection(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if err := client.Call(ctx, "echo", "hello", &got); err != nil || got != "hello" {
+			t.Fatalf("echo on connection %d returned %q, %v", i, got, err)
+		}
+		s.listener.kill()
+		reconnected(t, ctx, client, old)
+	}
+	if got := s.count("init"); got != 4 {
+		t.Errorf("the session was initialized %d times, want 4", got)
+	}
+}
+
+func TestClientInFlight(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		retry func(*jsonrpc2.Request) bool
+		want  int // the result of the slow call, 0 if it fails
+	}{
+		{"fail fast", nil, 0},
+		{"not idempotent", jsonrpc2.RetryMethods("echo"), 0},
+		{"retry", jsonrpc2.RetryMethods("slow"), 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stacktest.NoLeak(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			s, client, shutdown := startReconnect(t, ctx, jsonrpc2.ClientOptions{Retry: test.retry})
+			defer shutdown()
+
+			result := make(chan error, 1)
+			var got int
+			go func() { result <- client.Call(ctx, "slow", nil, &got) }()
+			<-s.started
+			s.listener.kill()
+			err := <-result
+			switch {
+			case test.want == 0:
+				if !errors.Is(err, io.ErrClosedPipe) {
+					t.Errorf("the call in flight returned %v, want it to fail with the connection", err)
+				}
+			case err != nil:
+				t.Errorf("the retried call failed: %v", err)
+			case got != test.want:
+				t.Errorf("the retried call returned %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestClientBackoff(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s, client, shutdown := startReconnect(t, ctx, jsonrpc2.ClientOptions{MaxAttempts: 4})
+	defer shutdown()
+
+	old, _ := client.Connection(ctx)
+	s.dialer.mu.Lock()
+	s.dialer.failures = 3
+	s.dialer.mu.Unlock()
+	s.listener.kill()
+	reconnected(t, ctx, client, old)
+	if s.dialer.dials != 5 {
+		t.Errorf("dialed %d times, want 5", s.dialer.dials)
+	}
+
+	// give up after too many failures
+	old, _ = client.Connection(ctx)
+	s.dialer.mu.Lock()
+	s.dialer.failures = 4
+	s.dialer.mu.Unlock()
+	s.listener.kill()
+	for {
+		conn, err := client.Connection(ctx)
+		if err != nil {
+			break
+		}
+		if conn != old {
+			t.Fatal("reconnected after too many failures")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := client.Call(ctx, "echo", nil, nil); err == nil || errors.Is(err, jsonrpc2.ErrClientClosed) {
+		t.Errorf("a call after giving up returned %v", err)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, client, shutdown := startReconnect(t, ctx, jsonrpc2.ClientOptions{})
+	defer shutdown()
+
+	client.Close()
+	if err := client.Call(ctx, "echo", nil, nil); err != jsonrpc2.ErrClientClosed {
+		t.Errorf("a call after Close returned %v, want ErrClientClosed", err)
+	}
+	if err := client.Notify(ctx, "echo", json.RawMessage("1")); err != jsonrpc2.ErrClientClosed {
+		t.Errorf("a notification after Close returned %v, want ErrClientClosed", err)
+	}
+}
@@ -40,9 +40,17 @@ type Dialer interface {
 
 // Server is a running server that is accepting incoming connections.
 type Server struct {
+	// ShutdownMethod, if not empty, is the method of a notification sent to
+	// each peer when Shutdown starts. It must be set before Shutdown is called.
+	ShutdownMethod string
+
 	listener Listener
 	binder   Binder
 	async    async
+	accepted chan struct{} // closed once no more connections are accepted
+
+	mu    sync.Mutex
+	conns []*Connection // the connections that were active when last checked
 }
 
 // Dial uses the dialer to make a new connection, wraps the returned
@@ -68,6 +76,7 @@ func Serve(ctx context.Context, listener Listener, binder Binder) (*Server, erro
 	server := &Server{
 		listener: listener,
 		binder:   binder,
+		accepted: make(chan struct{}),
 	}
 	server.async.init()
 	go server.run(ctx)
@@ -84,7 +93,6 @@ func (s *Server) Wait() error {
 // duration, otherwise it exits only on error.
 func (s *Server) run(ctx context.Context) {
 	defer s.async.done()
-	var activeConns []*Connection
 	for {
 		// we never close the accepted connection, we rely on the other end
 		// closing or the socket closing itself naturally
@@ -97,9 +105,6 @@ func (s *Server) run(ctx context.Context) {
 			break
 		}
 
-		// see if any connections were closed while we were waiting
-		activeConns = onlyActive(activeConns)
-
 		// a new inbound connection,
 		conn, err := newConnection(ctx, rwc, s.binder)
 		if err != nil {
@@ -108,15 +113,27 @@ func (s *Server) run(ctx context.Context) {
 			}
 			continue
 		}
-		activeConns = append(activeConns, conn)
+		s.mu.Lock()
+		// see if any connections were closed while we were waiting
+		s.conns = append(onlyActive(s.conns), conn)
+		s.mu.Unlock()
 	}
+	close(s.accepted)
 
 	// wait for all active conns to finish
-	for _, c := range activeConns {
+	for _, c := range s.activeConns() {
 		c.Wait()
 	}
 }
 
+// activeConns returns the connections that are still active.
+func (s *Server) activeConns() []*Connection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns = onlyActive(s.conns)
+	return append([]*Connection(nil), s.conns...)
+}
+
 func onlyActive(conns []*Connection) []*Connection {
 	i := 0
 	for _, c := range conns {
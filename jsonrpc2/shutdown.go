@@ -0,0 +1,112 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/event"
+)
+
+// ShutdownError is returned by Server.Shutdown when its context is done
+// before all the calls being handled have finished.
+type ShutdownError struct {
+	// Abandoned holds the calls that were still being handled.
+	Abandoned []*Request
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	methods := make([]string, len(e.Abandoned))
+	for i, req := range e.Abandoned {
+		methods[i] = fmt.Sprintf("%s(%v)", req.Method, req.ID.Raw())
+	}
+	return fmt.Sprintf("jsonrpc2: shutdown abandoned %d calls [%s]: %v", len(e.Abandoned), strings.Join(methods, ", "), e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error { return e.Err }
+
+// Shutdown stops the server gracefully.
+// It closes the listener, notifies the peers if ShutdownMethod is set, and
+// waits for the requests being handled by its connections to finish, while
+// answering new calls with ErrServerOverloaded. Then it cancels the requests
+// that are left, and closes the connections.
+// If ctx is done before all the calls have finished, Shutdown returns a
+// *ShutdownError listing the calls it abandoned.
+// Shutdown does not wait for the connections to finish closing; use Wait for
+// that.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.listener.Close(); err != nil && !isClosingError(err) {
+		return err
+	}
+	// wait for the listener to stop, so no connection is accepted after this
+	select {
+	case <-s.accepted:
+	case <-ctx.Done():
+		return &ShutdownError{Err: ctx.Err()}
+	}
+	conns := s.activeConns()
+	for _, c := range conns {
+		atomic.StoreInt32(&c.draining, 1)
+		if s.ShutdownMethod != "" {
+			if err := c.Notify(ctx, s.ShutdownMethod, nil); err != nil {
+				event.Error(ctx, "jsonrpc2 shutdown notification failed", err)
+			}
+		}
+	}
+	// poll for idle connections, at increasing intervals
+	var err error
+	delay := time.Millisecond
+	for err == nil && !allIdle(conns) {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+		}
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+	var abandoned []*Request
+	for _, c := range conns {
+		abandoned = append(abandoned, c.cancelIncoming()...)
+		c.closer.Close()
+	}
+	if err != nil {
+		return &ShutdownError{Abandoned: abandoned, Err: err}
+	}
+	return nil
+}
+
+func allIdle(conns []*Connection) bool {
+	for _, c := range conns {
+		if atomic.LoadInt64(&c.active) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// cancelIncoming cancels the calls being handled, and returns them.
+func (c *Connection) cancelIncoming() []*Request {
+	pending := <-c.incomingBox
+	defer func() { c.incomingBox <- pending }()
+	var calls []*Request
+	for _, entry := range pending {
+		calls = append(calls, entry.request)
+		if entry.cancel != nil {
+			entry.cancel()
+			entry.cancel = nil
+		}
+	}
+	return calls
+}
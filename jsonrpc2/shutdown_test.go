@@ -0,0 +1,222 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// startShutdown starts a server behind an idle listener whose handler blocks
+// "block" calls until release is closed or they are cancelled, and a client
+// that reports the notifications it receives.
+// Each blocked call is reported on started.
+func startShutdown(t *testing.T, ctx context.Context) (server *jsonrpc2.Server, client *jsonrpc2.Connection, started <-chan struct{}, release chan struct{}, notified <-chan string) {
+	release = make(chan struct{})
+	blocked := make(chan struct{}, 1)
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+		switch req.Method {
+		case "block":
+			blocked <- struct{}{}
+			select {
+			case <-release:
+				return "done", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		default:
+			return "ok", nil
+		}
+	})
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener = jsonrpc2.NewIdleListener(time.Hour, listener)
+	server, err = jsonrpc2.Serve(ctx, listener, jsonrpc2.ConnectionOptions{Handler: handler})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ShutdownMethod = "shutdown"
+	notes := make(chan string, 1)
+	client, err = jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{
+		Handler: jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+			notes <- req.Method
+			return nil, nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// make sure the server has accepted the connection
+	if err := client.Call(ctx, "hello", nil).Await(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	return server, client, blocked, release, notes
+}
+
+func TestShutdown(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server, client, started, release, notified := startShutdown(t, ctx)
+	defer client.Close()
+
+	call := client.Call(ctx, "block", nil)
+	<-started
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(ctx) }()
+	if got := <-notified; got != "shutdown" {
+		t.Fatalf("got notification %q, want shutdown", got)
+	}
+	// new calls are refused while draining
+	if err := client.Call(ctx, "hello", nil).Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrServerOverloaded) {
+		t.Errorf("a call while draining returned %v, want ErrServerOverloaded", err)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown finished with a call in flight: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	var result string
+	if err := call.Await(ctx, &result); err != nil || result != "done" {
+		t.Errorf("the call in flight returned %q, %v", result, err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown returned %v", err)
+	}
+	if err := server.Wait(); err != nil {
+		t.Errorf("the server finished with %v", err)
+	}
+	if err := client.Wait(); err == nil {
+		t.Error("the client connection finished without an error")
+	}
+}
+
+func TestShutdownAbandon(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server, client, started, _, notified := startShutdown(t, ctx)
+	defer client.Close()
+
+	call := client.Call(ctx, "block", nil)
+	<-started
+	sctx, scancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer scancel()
+	err := server.Shutdown(sctx)
+	var serr *jsonrpc2.ShutdownError
+	if !errors.As(err, &serr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown returned %v, want a ShutdownError", err)
+	}
+	if len(serr.Abandoned) != 1 || serr.Abandoned[0].Method != "block" || serr.Abandoned[0].ID != call.ID() {
+		t.Errorf("abandoned %v, want the block call", serr.Abandoned)
+	}
+	<-notified
+	if err := call.Await(ctx, nil); err == nil {
+		t.Error("the abandoned call succeeded")
+	}
+	server.Wait()
+}
+
+func TestShutdownIdle(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, jsonrpc2.NewIdleListener(time.Hour, listener), jsonrpc2.ConnectionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown returned %v", err)
+	}
+	// a shut down server is not reported as idle
+	if err := server.Wait(); err != nil {
+		t.Errorf("the server finished with %v", err)
+	}
+}
+
+// TestShutdownQueued checks that the calls read before Shutdown began are
+// handled, even those still waiting for room in the queue.
+func TestShutdownQueued(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+		if req.Method == "block" {
+			close(started)
+			<-release
+		}
+		return "ok", nil
+	})
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, jsonrpc2.ConnectionOptions{
+		Handler:   handler,
+		MaxQueued: 1,
+		Overload:  jsonrpc2.Backpressure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ShutdownMethod = "shutdown"
+	notified := make(chan struct{})
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{
+		Handler: jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+			close(notified)
+			return nil, nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	block := client.Call(ctx, "block", nil)
+	<-started
+	// the first call fills the queue, the second waits for room in it
+	queued := []*jsonrpc2.AsyncCall{
+		client.Call(ctx, "queued", nil),
+		client.Call(ctx, "queued", nil),
+	}
+	// the pipe is synchronous, so once this is written the calls were read
+	if err := client.Notify(ctx, "sync", nil); err != nil {
+		t.Fatal(err)
+	}
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(ctx) }()
+	<-notified
+	// the server reads no more until there is room in its queue
+	close(release)
+	late := client.Call(ctx, "late", nil)
+
+	for i, call := range append([]*jsonrpc2.AsyncCall{block}, queued...) {
+		var result string
+		if err := call.Await(ctx, &result); err != nil || result != "ok" {
+			t.Errorf("call %d returned %q, %v", i, result, err)
+		}
+	}
+	if err := late.Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrServerOverloaded) {
+		t.Errorf("a call while draining returned %v, want ErrServerOverloaded", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown returned %v", err)
+	}
+	server.Wait()
+}
@@ -0,0 +1,343 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/event"
+	errors "golang.org/x/xerrors"
+)
+
+// TraceEntry is a message read or written by a connection, as recorded by
+// TraceFramer, one JSON object per line.
+type TraceEntry struct {
+	// Time is when the message was read, or when it was about to be written.
+	Time time.Time `json:"time"`
+	// Direction is Inbound for messages read, and Outbound for messages
+	// written.
+	Direction string `json:"direction"`
+	// Message is the message in wire form.
+	Message json.RawMessage `json:"message"`
+}
+
+// TraceFramer returns a Framer that reads and writes messages with framer,
+// and records each of them to w as a TraceEntry on its own line.
+// Messages written are recorded before the write, so the trace may include a
+// message that failed to be written.
+// Failures to record are logged as error events, and do not affect the
+// connection.
+func TraceFramer(framer Framer, w io.Writer) Framer {
+	return &traceFramer{framer: framer, log: &traceLog{enc: json.NewEncoder(w)}}
+}
+
+type traceFramer struct {
+	framer Framer
+	log    *traceLog
+}
+
+// traceLog writes entries for both the reader and the writer of a
+// connection.
+type traceLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+type traceReader struct {
+	in  Reader
+	log *traceLog
+}
+
+type traceWriter struct {
+	out Writer
+	log *traceLog
+}
+
+func (f *traceFramer) Reader(rw io.Reader) Reader {
+	return &traceReader{in: f.framer.Reader(rw), log: f.log}
+}
+
+func (f *traceFramer) Writer(rw io.Writer) Writer {
+	return &traceWriter{out: f.framer.Writer(rw), log: f.log}
+}
+
+func (r *traceReader) Read(ctx context.Context) (Message, int64, error) {
+	msg, n, err := r.in.Read(ctx)
+	if err == nil {
+		r.log.record(ctx, Inbound, msg)
+	}
+	return msg, n, err
+}
+
+func (w *traceWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	// Record before writing, as the peer may answer before Write returns, and
+	// its answer must not be recorded ahead of the message it answers.
+	w.log.record(ctx, Outbound, msg)
+	return w.out.Write(ctx, msg)
+}
+
+func (l *traceLog) record(ctx context.Context, direction string, msg Message) {
+	data, err := EncodeMessage(msg)
+	if err == nil {
+		l.mu.Lock()
+		err = l.enc.Encode(TraceEntry{Time: time.Now(), Direction: direction, Message: data})
+		l.mu.Unlock()
+	}
+	if err != nil {
+		event.Error(ctx, "jsonrpc2 trace failed", err)
+	}
+}
+
+// ReadTrace reads the entries recorded by a TraceFramer.
+func ReadTrace(r io.Reader) ([]TraceEntry, error) {
+	var entries []TraceEntry
+	dec := json.NewDecoder(r)
+	for {
+		var entry TraceEntry
+		if err := dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return entries, errors.Errorf("reading trace entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// ReplayOptions holds the options for Replay.
+type ReplayOptions struct {
+	// Client is the direction of the messages of the client in the trace,
+	// which is Outbound for traces recorded by the client, and Inbound for
+	// traces recorded by the server.
+	// If empty, Outbound is used.
+	Client string
+	// Wait is how long to wait for each message the server is expected to
+	// send. If zero, 5 seconds is used.
+	Wait time.Duration
+	// Framer must match the Framer of the connections built by the binder.
+	// If nil, HeaderFramer is used.
+	Framer Framer
+}
+
+// ReplayDiff is a difference between the messages of the server in a trace
+// and those of a replay.
+type ReplayDiff struct {
+	// Entry is the index in the trace of the expected message, or -1 for an
+	// unexpected message.
+	Entry int
+	// Want is the expected message, nil if the message was unexpected.
+	Want json.RawMessage
+	// Got is the message of the replay, nil if it was missing.
+	Got json.RawMessage
+}
+
+func (d ReplayDiff) String() string {
+	switch {
+	case d.Want == nil:
+		return fmt.Sprintf("unexpected %s", d.Got)
+	case d.Got == nil:
+		return fmt.Sprintf("entry %d: missing %s", d.Entry, d.Want)
+	default:
+		return fmt.Sprintf("entry %d: got %s, want %s", d.Entry, d.Got, d.Want)
+	}
+}
+
+// Replay plays the client side of a trace against a server whose connection
+// is built by binder, and compares the messages of the server with those in
+// the trace.
+// The messages of the client are sent in the order of the trace, each after
+// the messages of the server that preceded it in the trace have arrived.
+// Responses are matched by ID, and requests from the server by method, in
+// order. Messages of the server that match nothing in the trace are reported
+// if they arrived by the end of the replay.
+// The returned error is for failures of the replay itself.
+func Replay(ctx context.Context, trace []TraceEntry, binder Binder, opts ReplayOptions) ([]ReplayDiff, error) {
+	if opts.Client == "" {
+		opts.Client = Outbound
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = 5 * time.Second
+	}
+	if opts.Framer == nil {
+		opts.Framer = HeaderFramer()
+	}
+	listener, err := NetPipe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	server, err := Serve(ctx, listener, binder)
+	if err != nil {
+		return nil, err
+	}
+	rwc, err := listener.Dialer().Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := &replay{
+		wait:     opts.Wait,
+		got:      make(chan Message),
+		done:     make(chan struct{}),
+		requests: make(map[string][]Message),
+	}
+	go r.read(ctx, opts.Framer.Reader(rwc))
+	defer func() {
+		close(r.done)
+		rwc.Close()
+		listener.Close()
+		server.Wait()
+	}()
+	writer := opts.Framer.Writer(rwc)
+	for i, entry := range trace {
+		msg, err := DecodeMessage(entry.Message)
+		if err != nil {
+			return r.diffs, errors.Errorf("trace entry %d: %w", i, err)
+		}
+		if entry.Direction == opts.Client {
+			if _, err := writer.Write(ctx, msg); err != nil {
+				return r.diffs, errors.Errorf("replaying trace entry %d: %w", i, err)
+			}
+			continue
+		}
+		msgs := []Message{msg}
+		if batch, ok := msg.(Batch); ok {
+			msgs = batch
+		}
+		for _, want := range msgs {
+			r.expect(ctx, i, want)
+		}
+	}
+	r.drain()
+	return r.diffs, nil
+}
+
+// replay holds the messages of the server that have not been matched yet.
+type replay struct {
+	wait      time.Duration
+	got       chan Message
+	done      chan struct{}
+	responses []*Response
+	requests  map[string][]Message // by method
+	diffs     []ReplayDiff
+}
+
+func (r *replay) read(ctx context.Context, reader Reader) {
+	for {
+		msg, _, err := reader.Read(ctx)
+		if err != nil {
+			return
+		}
+		select {
+		case r.got <- msg:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *replay) add(msg Message) {
+	switch msg := msg.(type) {
+	case *Response:
+		r.responses = append(r.responses, msg)
+	case *Request:
+		r.requests[msg.Method] = append(r.requests[msg.Method], msg)
+	case Batch:
+		for _, m := range msg {
+			r.add(m)
+		}
+	}
+}
+
+// take removes and returns the unmatched message that corresponds to want,
+// if there is one.
+func (r *replay) take(want Message) Message {
+	switch want := want.(type) {
+	case *Response:
+		for i, got := range r.responses {
+			if got.ID == want.ID {
+				r.responses = append(r.responses[:i], r.responses[i+1:]...)
+				return got
+			}
+		}
+	case *Request:
+		if got := r.requests[want.Method]; len(got) > 0 {
+			r.requests[want.Method] = got[1:]
+			return got[0]
+		}
+	}
+	return nil
+}
+
+// expect waits for the message of the server that corresponds to want, and
+// records a difference if it is missing or not the same.
+func (r *replay) expect(ctx context.Context, entry int, want Message) {
+	timer := time.NewTimer(r.wait)
+	defer timer.Stop()
+	got := r.take(want)
+	for got == nil {
+		var msg Message
+		select {
+		case msg = <-r.got:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		if msg == nil {
+			break
+		}
+		r.add(msg)
+		got = r.take(want)
+	}
+	wantData, _ := EncodeMessage(want)
+	if got == nil {
+		r.diffs = append(r.diffs, ReplayDiff{Entry: entry, Want: wantData})
+		return
+	}
+	gotData, _ := EncodeMessage(got)
+	if !sameJSON(wantData, gotData) {
+		r.diffs = append(r.diffs, ReplayDiff{Entry: entry, Want: wantData, Got: gotData})
+	}
+}
+
+// drain records the messages of the server that were never matched.
+func (r *replay) drain() {
+	for more := true; more; {
+		select {
+		case msg := <-r.got:
+			r.add(msg)
+		default:
+			more = false
+		}
+	}
+	for _, msg := range r.responses {
+		data, _ := EncodeMessage(msg)
+		r.diffs = append(r.diffs, ReplayDiff{Entry: -1, Got: data})
+	}
+	methods := make([]string, 0, len(r.requests))
+	for method := range r.requests {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		for _, msg := range r.requests[method] {
+			data, _ := EncodeMessage(msg)
+			r.diffs = append(r.diffs, ReplayDiff{Entry: -1, Got: data})
+		}
+	}
+}
+
+// sameJSON reports whether a and b hold the same JSON value.
+func sameJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
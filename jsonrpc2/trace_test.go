@@ -0,0 +1,161 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// countBinder binds connections with a handler that echoes "echo" calls,
+// and counts "count" calls from 1, adding offset.
+// It never answers "hang".
+type countBinder struct {
+	offset int
+}
+
+func (b countBinder) Bind(context.Context, *jsonrpc2.Connection) (jsonrpc2.ConnectionOptions, error) {
+	n := b.offset
+	return jsonrpc2.ConnectionOptions{
+		Handler: jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+			switch req.Method {
+			case "echo":
+				return req.Params, nil
+			case "count":
+				n++
+				return n, nil
+			case "hang":
+				return nil, jsonrpc2.ErrAsyncResponse
+			case "note":
+				return nil, nil
+			default:
+				return nil, jsonrpc2.ErrNotHandled
+			}
+		}),
+	}, nil
+}
+
+// recordTrace runs a session against countBinder with a tracing client, and
+// returns the trace.
+func recordTrace(t *testing.T, ctx context.Context) []jsonrpc2.TraceEntry {
+	listener, err := jsonrpc2.NetPipe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := jsonrpc2.Serve(ctx, listener, countBinder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.ConnectionOptions{
+		Framer: jsonrpc2.TraceFramer(jsonrpc2.HeaderFramer(), &buf),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(ctx, "echo", "hello").Await(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Notify(ctx, "note", nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := client.Call(ctx, "count", nil).Await(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listener.Close()
+	client.Close()
+	server.Wait()
+
+	trace, err := jsonrpc2.ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trace
+}
+
+func TestTraceFramer(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	trace := recordTrace(t, ctx)
+	want := []string{
+		`out {"jsonrpc":"2.0","id":1,"method":"echo","params":"hello"}`,
+		`in {"jsonrpc":"2.0","id":1,"result":"hello"}`,
+		`out {"jsonrpc":"2.0","method":"note"}`,
+		`out {"jsonrpc":"2.0","id":2,"method":"count"}`,
+		`in {"jsonrpc":"2.0","id":2,"result":1}`,
+		`out {"jsonrpc":"2.0","id":3,"method":"count"}`,
+		`in {"jsonrpc":"2.0","id":3,"result":2}`,
+	}
+	var got []string
+	for i, entry := range trace {
+		got = append(got, entry.Direction+" "+string(entry.Message))
+		if i > 0 && entry.Time.Before(trace[i-1].Time) {
+			t.Errorf("entry %d is earlier than the one before", i)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got trace\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestReplay(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	trace := recordTrace(t, ctx)
+
+	diffs, err := jsonrpc2.Replay(ctx, trace, countBinder{}, jsonrpc2.ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("replaying against the same server got diffs %v", diffs)
+	}
+
+	// a server that counts differently
+	diffs, err = jsonrpc2.Replay(ctx, trace, countBinder{offset: 1}, jsonrpc2.ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`entry 4: got {"jsonrpc":"2.0","id":2,"result":2}, want {"jsonrpc":"2.0","id":2,"result":1}`,
+		`entry 6: got {"jsonrpc":"2.0","id":3,"result":3}, want {"jsonrpc":"2.0","id":3,"result":2}`,
+	}
+	checkDiffs(t, diffs, want)
+
+	// a server that does not answer, and one that answers what was not asked
+	trace[0].Message = json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"hang"}`)
+	trace[2].Message = json.RawMessage(`{"jsonrpc":"2.0","id":9,"method":"echo","params":1}`)
+	diffs, err = jsonrpc2.Replay(ctx, trace, countBinder{}, jsonrpc2.ReplayOptions{Wait: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		`entry 1: missing {"jsonrpc":"2.0","id":1,"result":"hello"}`,
+		`unexpected {"jsonrpc":"2.0","id":9,"result":1}`,
+	}
+	checkDiffs(t, diffs, want)
+}
+
+func checkDiffs(t *testing.T, diffs []jsonrpc2.ReplayDiff, want []string) {
+	t.Helper()
+	var got []string
+	for _, d := range diffs {
+		got = append(got, d.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diffs\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
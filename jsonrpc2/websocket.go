@@ -0,0 +1,575 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	errors "golang.org/x/xerrors"
+)
+
+// This file contains a Listener and Dialer that carry JSON RPC over WebSocket
+// connections, as described in RFC 6455. Each JSON RPC message is sent as a
+// single text message.
+
+// defaultMaxWebSocketMessage is the largest message a WebSocket connection
+// will read by default, to protect against peers that announce huge messages.
+const defaultMaxWebSocketMessage = 32 << 20
+
+// webSocketGUID is used to compute the Sec-WebSocket-Accept header.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+var errWebSocketProtocol = errors.New("websocket protocol error")
+
+// errWebSocketTooBig is the protocol error for messages over the size limit.
+var errWebSocketTooBig = errors.Errorf("%w: message too big", errWebSocketProtocol)
+
+// WebSocketOptions configures the connections of a WebSocketListener or a
+// WebSocketDialer. All its fields are optional.
+type WebSocketOptions struct {
+	// MaxMessageSize is the size in bytes of the largest message a connection
+	// reads, a larger one is an error. Zero means 32 MiB.
+	MaxMessageSize int64
+
+	// CheckOrigin is called by a WebSocketListener with each upgrade request,
+	// which is refused if it returns false.
+	// If nil, a request with an Origin header is only accepted if the origin
+	// has the same host as the request, so that the web pages of other sites
+	// cannot connect to the server from the browser of a user.
+	CheckOrigin func(r *http.Request) bool
+
+	// Header is added to the upgrade requests of a WebSocketDialer.
+	Header http.Header
+}
+
+func (o *WebSocketOptions) maxMessageSize() int64 {
+	if o == nil || o.MaxMessageSize <= 0 {
+		return defaultMaxWebSocketMessage
+	}
+	return o.MaxMessageSize
+}
+
+// sameOrigin reports whether the request has no Origin header, or one with
+// the same host as the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// WebSocketListener is a Listener for connections made by upgrading HTTP
+// requests to WebSocket.
+// It is an http.Handler, and must be installed in an HTTP server to receive
+// the requests.
+type WebSocketListener struct {
+	checkOrigin func(*http.Request) bool
+	maxMessage  int64
+	conns       chan io.ReadWriteCloser
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewWebSocketListener returns a new WebSocketListener.
+// If opts is nil, the defaults of WebSocketOptions are used.
+func NewWebSocketListener(opts *WebSocketOptions) *WebSocketListener {
+	l := &WebSocketListener{
+		checkOrigin: sameOrigin,
+		maxMessage:  opts.maxMessageSize(),
+		conns:       make(chan io.ReadWriteCloser),
+		done:        make(chan struct{}),
+	}
+	if opts != nil && opts.CheckOrigin != nil {
+		l.checkOrigin = opts.CheckOrigin
+	}
+	return l
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection, and hands it to
+// a pending call to Accept.
+func (l *WebSocketListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a WebSocket upgrade request", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	if !l.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	select {
+	case <-l.done:
+		http.Error(w, "listener closed", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return
+	}
+	nc, brw, err := hj.Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		nc.Close()
+		return
+	}
+	conn := newWebSocketConn(nc, brw.Reader, false, l.maxMessage)
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// Accept blocks waiting for an incoming connection to the listener.
+func (l *WebSocketListener) Accept(ctx context.Context) (io.ReadWriteCloser, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close will cause the listener to stop accepting connections. It will not
+// close any connections that have already been accepted, or the HTTP server.
+func (l *WebSocketListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+// Dialer returns nil, as the listener does not know the URL it is served at.
+// Use WebSocketDialer instead.
+func (l *WebSocketListener) Dialer() Dialer { return nil }
+
+// WebSocketDialer returns a Dialer that connects to the WebSocket server at
+// the given ws:// or wss:// URL.
+// If opts is nil, the defaults of WebSocketOptions are used.
+func WebSocketDialer(rawURL string, opts *WebSocketOptions) Dialer {
+	d := &webSocketDialer{url: rawURL, maxMessage: opts.maxMessageSize()}
+	if opts != nil {
+		d.header = opts.Header
+	}
+	return d
+}
+
+type webSocketDialer struct {
+	url        string
+	header     http.Header
+	maxMessage int64
+}
+
+func (d *webSocketDialer) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	u, err := url.Parse(d.url)
+	if err != nil {
+		return nil, err
+	}
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme = "https"
+		secure = true
+	default:
+		return nil, errors.Errorf("unsupported WebSocket URL scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if secure {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	var nc net.Conn
+	if secure {
+		td := &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+		nc, err = td.DialContext(ctx, "tcp", host)
+	} else {
+		var nd net.Dialer
+		nc, err = nd.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn, err := webSocketHandshake(ctx, nc, u, d.header, d.maxMessage)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// webSocketHandshake sends the upgrade request on nc, and checks the
+// response.
+func webSocketHandshake(ctx context.Context, nc net.Conn, u *url.URL, header http.Header, maxMessage int64) (*webSocketConn, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+		defer nc.SetDeadline(time.Time{})
+	}
+	if err := req.Write(nc); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, errors.Errorf("WebSocket upgrade failed: %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		return nil, errors.Errorf("%w: invalid Sec-WebSocket-Accept header", errWebSocketProtocol)
+	}
+	return newWebSocketConn(nc, br, true, maxMessage), nil
+}
+
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains reports whether the comma separated list in the named header
+// contains token, ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebSocketFramer returns a Framer for connections made by a
+// WebSocketListener or WebSocketDialer, which sends each JSON RPC message as a
+// single WebSocket text message.
+// If the connection was wrapped, for instance by NewIdleListener, the messages
+// are read as a stream of JSON values instead, as by RawFramer.
+func WebSocketFramer() Framer { return webSocketFramer{} }
+
+type webSocketFramer struct{}
+type webSocketReader struct{ in messageReader }
+
+// messageReader is implemented by streams that preserve message boundaries.
+type messageReader interface {
+	ReadMessage() ([]byte, error)
+}
+
+func (webSocketFramer) Reader(rw io.Reader) Reader {
+	if mr, ok := rw.(messageReader); ok {
+		return &webSocketReader{in: mr}
+	}
+	return RawFramer().Reader(rw)
+}
+
+func (webSocketFramer) Writer(rw io.Writer) Writer {
+	// each Write on a WebSocket connection sends a single message
+	return RawFramer().Writer(rw)
+}
+
+func (r *webSocketReader) Read(ctx context.Context) (Message, int64, error) {
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+	data, err := r.in.ReadMessage()
+	if err != nil {
+		return nil, int64(len(data)), err
+	}
+	msg, err := DecodeMessage(data)
+	return msg, int64(len(data)), err
+}
+
+// webSocketConn is an established WebSocket connection.
+// Each Write sends a text message; Read returns the contents of the messages
+// received, and never returns data from more than one message.
+type webSocketConn struct {
+	nc     net.Conn
+	br     *bufio.Reader
+	client bool  // clients mask the frames they send
+	max    int64 // the size of the largest message to read
+
+	readBuf []byte // the unread part of the last message
+
+	writeMu    sync.Mutex // guards writes, which come from Write and the reader
+	closeSent  bool
+	closeOnce  sync.Once
+	closeError error
+}
+
+func newWebSocketConn(nc net.Conn, br *bufio.Reader, client bool, max int64) *webSocketConn {
+	return &webSocketConn{nc: nc, br: br, client: client, max: max}
+}
+
+func (c *webSocketConn) Read(p []byte) (int, error) {
+	if len(c.readBuf) == 0 {
+		msg, err := c.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		c.readBuf = msg
+	}
+	n := copy(p, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+	return n, nil
+}
+
+// ReadMessage returns the contents of the next text or binary message,
+// answering any control frames that arrive before it.
+// It returns io.EOF once the peer has closed the connection.
+// A protocol error fails the connection, telling the peer why it is closed.
+func (c *webSocketConn) ReadMessage() ([]byte, error) {
+	msg, err := c.readMessage()
+	switch {
+	case errors.Is(err, errWebSocketTooBig):
+		c.closeWith([]byte{0x03, 0xF1}) // 1009: message too big
+	case errors.Is(err, errWebSocketProtocol):
+		c.closeWith([]byte{0x03, 0xEA}) // 1002: protocol error
+	}
+	return msg, err
+}
+
+func (c *webSocketConn) readMessage() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+		case wsPong:
+			// we never send pings, so there is nothing to do
+		case wsClose:
+			// echo the status code, as required, and stop reading
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			c.writeClose(payload)
+			return nil, io.EOF
+		case wsText, wsBinary, wsContinuation:
+			if started == (opcode != wsContinuation) {
+				return nil, errors.Errorf("%w: unexpected frame opcode %d", errWebSocketProtocol, opcode)
+			}
+			started = true
+			if int64(len(msg)+len(payload)) > c.max {
+				return nil, errors.Errorf("%w: larger than %d bytes", errWebSocketTooBig, c.max)
+			}
+			msg = append(msg, payload...)
+			if fin {
+				return msg, nil
+			}
+		default:
+			return nil, errors.Errorf("%w: unknown frame opcode %d", errWebSocketProtocol, opcode)
+		}
+	}
+}
+
+func (c *webSocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = hdr[0]&0x80 != 0
+	opcode = hdr[0] & 0x0F
+	if hdr[0]&0x70 != 0 {
+		return false, 0, nil, errors.Errorf("%w: reserved bits set", errWebSocketProtocol)
+	}
+	masked := hdr[1]&0x80 != 0
+	if masked == c.client {
+		// servers must not mask frames, clients must
+		return false, 0, nil, errors.Errorf("%w: wrong frame masking", errWebSocketProtocol)
+	}
+	length := uint64(hdr[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsClose && (!fin || length > 125) {
+		return false, 0, nil, errors.Errorf("%w: invalid control frame", errWebSocketProtocol)
+	}
+	if length > uint64(c.max) {
+		return false, 0, nil, errors.Errorf("%w: larger than %d bytes", errWebSocketTooBig, c.max)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// Write sends p as a single text message.
+func (c *webSocketConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsText, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return io.ErrClosedPipe
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *webSocketConn) writeFrameLocked(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(frame, maskBit|127)
+		frame = append(frame, ext[:]...)
+	}
+	if !c.client {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+	_, err := c.nc.Write(frame)
+	return err
+}
+
+// writeClose sends a close frame, unless one was already sent.
+func (c *webSocketConn) writeClose(payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+	return c.writeFrameLocked(wsClose, payload)
+}
+
+// Close sends a normal closure frame to the peer and closes the underlying
+// connection.
+func (c *webSocketConn) Close() error {
+	return c.closeWith([]byte{0x03, 0xE8}) // 1000: normal closure
+}
+
+// closeWith sends a close frame with the given status to the peer, unless the
+// connection is already closed, and closes the underlying connection.
+func (c *webSocketConn) closeWith(status []byte) error {
+	c.closeOnce.Do(func() {
+		c.writeClose(status)
+		c.closeError = c.nc.Close()
+	})
+	return c.closeError
+}
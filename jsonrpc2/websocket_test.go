@@ -0,0 +1,204 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/jsonrpc2"
+	"golang.org/x/exp/jsonrpc2/internal/stack/stacktest"
+)
+
+// echoHandler returns the params of each call as its result.
+var echoHandler = jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+	return req.Params, nil
+})
+
+func startWebSocket(t *testing.T, ctx context.Context, handler jsonrpc2.Handler, opts *jsonrpc2.WebSocketOptions) (url string, shutdown func()) {
+	listener := jsonrpc2.NewWebSocketListener(opts)
+	httpServer := httptest.NewServer(listener)
+	server, err := jsonrpc2.Serve(ctx, listener, jsonrpc2.ConnectionOptions{
+		Framer:  jsonrpc2.WebSocketFramer(),
+		Handler: handler,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "ws" + strings.TrimPrefix(httpServer.URL, "http"), func() {
+		listener.Close()
+		server.Wait()
+		httpServer.Close()
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url, shutdown := startWebSocket(t, ctx, echoHandler, nil)
+	defer shutdown()
+
+	conn, err := jsonrpc2.Dial(ctx, jsonrpc2.WebSocketDialer(url, nil), jsonrpc2.ConnectionOptions{
+		Framer: jsonrpc2.WebSocketFramer(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// sizes that use each of the three length encodings of a frame
+	for _, size := range []int{10, 1000, 100000} {
+		want := strings.Repeat("x", size)
+		var got string
+		if err := conn.Call(ctx, "echo", want).Await(ctx, &got); err != nil {
+			t.Fatalf("call with %d bytes: %v", size, err)
+		}
+		if got != want {
+			t.Errorf("call with %d bytes: got %d bytes back", size, len(got))
+		}
+	}
+}
+
+// TestWebSocketMessages checks that each JSON RPC message is a single
+// WebSocket message.
+func TestWebSocketMessages(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url, shutdown := startWebSocket(t, ctx, echoHandler, nil)
+	defer shutdown()
+
+	rwc, err := jsonrpc2.WebSocketDialer(url, nil).Dial(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rwc.Close()
+	for _, msg := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"echo","params":"one"}`,
+		`[{"jsonrpc":"2.0","id":2,"method":"echo","params":"two"},{"jsonrpc":"2.0","id":3,"method":"echo","params":3}]`,
+	} {
+		if _, err := rwc.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a read never returns more than one message
+	buf := make([]byte, 1024)
+	for _, want := range []string{
+		`{"jsonrpc":"2.0","id":1,"result":"one"}`,
+		`[{"jsonrpc":"2.0","id":2,"result":"two"},{"jsonrpc":"2.0","id":3,"result":3}]`,
+	} {
+		n, err := rwc.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != want {
+			t.Errorf("got message %s, want %s", got, want)
+		}
+	}
+}
+
+func TestWebSocketUpgrade(t *testing.T) {
+	listener := jsonrpc2.NewWebSocketListener(nil)
+	defer listener.Close()
+	httpServer := httptest.NewServer(listener)
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET: got status %s, want %d", resp.Status, http.StatusBadRequest)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, httpServer.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("old version: got status %s, version %q", resp.Status, resp.Header.Get("Sec-WebSocket-Version"))
+	}
+
+	ctx := context.Background()
+	rwc, err := jsonrpc2.WebSocketDialer(httpServer.URL+"/", nil).Dial(ctx)
+	if err != nil {
+		t.Fatalf("dialing an http URL: %v", err)
+	}
+	rwc.Close()
+	if _, err := jsonrpc2.WebSocketDialer("ftp://localhost/", nil).Dial(ctx); err == nil {
+		t.Error("dialing an ftp URL succeeded")
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url, shutdown := startWebSocket(t, ctx, echoHandler, nil)
+	defer shutdown()
+	allowAll := &jsonrpc2.WebSocketOptions{CheckOrigin: func(*http.Request) bool { return true }}
+	allURL, allShutdown := startWebSocket(t, ctx, echoHandler, allowAll)
+	defer allShutdown()
+
+	self := "http" + strings.TrimPrefix(url, "ws")
+	for _, test := range []struct {
+		url    string
+		origin string
+		ok     bool
+	}{
+		{url, "", true},
+		{url, self, true},
+		{url, "http://evil.example", false},
+		{url, "null", false},
+		{allURL, "http://evil.example", true},
+	} {
+		opts := &jsonrpc2.WebSocketOptions{Header: http.Header{}}
+		if test.origin != "" {
+			opts.Header.Set("Origin", test.origin)
+		}
+		rwc, err := jsonrpc2.WebSocketDialer(test.url, opts).Dial(ctx)
+		if err == nil {
+			rwc.Close()
+		}
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("origin %q: got error %v, want accepted %v", test.origin, err, test.ok)
+		}
+	}
+}
+
+func TestWebSocketMaxMessageSize(t *testing.T) {
+	stacktest.NoLeak(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url, shutdown := startWebSocket(t, ctx, echoHandler, &jsonrpc2.WebSocketOptions{MaxMessageSize: 1000})
+	defer shutdown()
+
+	conn, err := jsonrpc2.Dial(ctx, jsonrpc2.WebSocketDialer(url, nil), jsonrpc2.ConnectionOptions{
+		Framer: jsonrpc2.WebSocketFramer(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var got string
+	if err := conn.Call(ctx, "echo", "small").Await(ctx, &got); err != nil {
+		t.Fatalf("small call: %v", err)
+	}
+	// the server drops the connection on reading the large message
+	if err := conn.Call(ctx, "echo", strings.Repeat("x", 2000)).Await(ctx, &got); err == nil {
+		t.Fatal("call larger than the maximum message size succeeded")
+	}
+}
@@ -33,6 +33,9 @@ var (
 	// ErrServerOverloaded is returned when a message was refused due to a
 	// server being temporarily unable to accept any new messages.
 	ErrServerOverloaded = NewError(-32000, "JSON RPC overloaded")
+	// ErrRequestCancelled is returned for a call that was cancelled with
+	// Connection.Cancel, usually at the request of the caller.
+	ErrRequestCancelled = NewError(-32800, "JSON RPC cancelled")
 )
 
 const wireVersion = "2.0"
@@ -72,3 +75,10 @@ func NewError(code int64, message string) error {
 func (err *wireError) Error() string {
 	return err.Message
 }
+
+// Is reports whether target is an error with the same code, so that errors
+// received from a peer match the errors of this package.
+func (err *wireError) Is(target error) bool {
+	w, ok := target.(*wireError)
+	return ok && err.Code == w.Code
+}
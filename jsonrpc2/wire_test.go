@@ -47,6 +47,28 @@ func TestWireMessage(t *testing.T) {
 			"message":"computing fix edits"
 		}
 	}`),
+	}, {
+		name: "batch",
+		msg: jsonrpc2.Batch{
+			newCall(1, "ping", nil),
+			newNotification("alive", nil),
+			newCall("msg3", "poke", nil),
+		},
+		encoded: []byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"ping"},
+		{"jsonrpc":"2.0","method":"alive"},
+		{"jsonrpc":"2.0","id":"msg3","method":"poke"}
+	]`),
+	}, {
+		name: "batch response",
+		msg: jsonrpc2.Batch{
+			newResponse(1, "pong", nil),
+			newResponse("msg3", nil, jsonrpc2.NewError(2, "sore")),
+		},
+		encoded: []byte(`[
+		{"jsonrpc":"2.0","id":1,"result":"pong"},
+		{"jsonrpc":"2.0","id":"msg3","error":{"code":2,"message":"sore"}}
+	]`),
 	}} {
 		b, err := jsonrpc2.EncodeMessage(test.msg)
 		if err != nil {
@@ -63,6 +85,30 @@ func TestWireMessage(t *testing.T) {
 	}
 }
 
+func TestWireBatchErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", `[]`},
+		{"nested", `[{"jsonrpc":"2.0","method":"alive"},[{"jsonrpc":"2.0","method":"alive"}]]`},
+		{"invalid member", `[{"jsonrpc":"2.0","method":"alive"},1]`},
+		{"bad version", ` [{"jsonrpc":"1.0","method":"alive"}]`},
+	} {
+		if msg, err := jsonrpc2.DecodeMessage([]byte(test.encoded)); err == nil {
+			t.Errorf("%s: decoded %s without error as %+v", test.name, test.encoded, msg)
+		}
+	}
+	for _, batch := range []jsonrpc2.Batch{
+		{},
+		{newNotification("alive", nil), jsonrpc2.Batch{newNotification("alive", nil)}},
+	} {
+		if b, err := jsonrpc2.EncodeMessage(batch); err == nil {
+			t.Errorf("encoded invalid batch %v without error as %s", batch, b)
+		}
+	}
+}
+
 func newNotification(method string, params interface{}) jsonrpc2.Message {
 	msg, err := jsonrpc2.NewNotification(method, params)
 	if err != nil {